@@ -0,0 +1,350 @@
+package main
+
+import (
+	"okinoko_escrow/sdk"
+	"strconv"
+	"strings"
+)
+
+// =====================
+// Amendments
+// =====================
+
+// amendment holds a pending change proposal of an escrow.
+// Changes are stored as key=value fields (am, n, to, arb, dl).
+type amendment struct {
+	ID        uint64
+	Proposer  string
+	Confirmed uint8 // bit per confirmed role (1=from, 2=to, 4=arb)
+	Changes   []string
+}
+
+// ProposeAmendment proposes changes to an escrow (EscrowID|key=value|...).
+// Supported keys are am (reduced amount in milli), n (name), to (receiver),
+// arb (arbitrator) and dl (deadline block height of deposits and metered escrows).
+// A new proposal replaces a pending one.
+//
+//go:wasmexport e_propose_amendment
+func ProposeAmendment(payload *string) *string {
+	if payload == nil || *payload == "" {
+		sdk.Abort("input CSV is nil or empty")
+	}
+	parts := strings.Split(*payload, "|")
+	if len(parts) < 2 {
+		sdk.Abort("invalid CSV format: expected EscrowID|key=value[|key=value...]")
+	}
+	escrowID := StringToUInt64(&parts[0])
+	requireAmendable(escrowID)
+
+	sender := actingAddress()
+	roles := loadRoles(escrowID)
+	role := amendmentRole(*sender, roles, nil)
+
+	changes := parts[1:]
+	applyAmendment(escrowID, changes, roles, false)
+
+	a := amendment{
+		ID:        nextAmendmentID(escrowID),
+		Proposer:  *sender,
+		Confirmed: role,
+		Changes:   changes,
+	}
+	saveAmendment(escrowID, a)
+
+	txID := sdk.GetEnvKey("tx.id")
+	EmitAmendmentProposedEvent(escrowID, a.ID, *sender, strings.Join(changes, ","), *txID)
+	result := strconv.FormatUint(a.ID, 10)
+	return &result
+}
+
+// ConfirmAmendment confirms a pending amendment (EscrowID|ProposalID).
+// The changes apply once both sender and receiver confirmed; replacing the arbitrator
+// additionally needs the confirmation of the outgoing arbitrator.
+//
+//go:wasmexport e_confirm_amendment
+func ConfirmAmendment(payload *string) *string {
+	if payload == nil || *payload == "" {
+		sdk.Abort("input CSV is nil or empty")
+	}
+	idStr, pidStr, found := strings.Cut(*payload, "|")
+	if !found {
+		sdk.Abort("invalid CSV format: expected EscrowID|ProposalID")
+	}
+	escrowID := StringToUInt64(&idStr)
+	requireAmendable(escrowID)
+
+	a := loadAmendment(escrowID)
+	if a == nil || a.ID != StringToUInt64(&pidStr) {
+		sdk.Abort("amendment not found")
+	}
+
+	sender := actingAddress()
+	roles := loadRoles(escrowID)
+	a.Confirmed |= amendmentRole(*sender, roles, a.Changes)
+
+	txID := sdk.GetEnvKey("tx.id")
+	EmitAmendmentConfirmedEvent(escrowID, a.ID, *sender, *txID)
+	if a.Confirmed != requiredConfirmations(a.Changes) {
+		saveAmendment(escrowID, *a)
+		return nil
+	}
+
+	applyAmendment(escrowID, a.Changes, roles, true)
+	deleteAmendment(escrowID)
+	EmitAmendmentAppliedEvent(escrowID, a.ID, *txID)
+	return nil
+}
+
+// =====================
+// Amendment Helpers
+// =====================
+
+// requireAmendable aborts unless the escrow is active, open and neither a wager nor co-funded.
+// The sender of a co-funded escrow holds only part of the money and cannot change its terms alone.
+func requireAmendable(escrowID uint64) {
+	switch loadKind(escrowID) {
+	case KindWager:
+		sdk.Abort("wagers cannot be amended")
+	case KindCofunded:
+		sdk.Abort("co-funded escrows cannot be amended")
+	}
+	if loadStatus(escrowID) != StatusActive {
+		sdk.Abort("escrow not active")
+	}
+	if closed, _ := loadEscrowOutcome(escrowID, loadDecisions(escrowID)); closed {
+		sdk.Abort("escrow already closed")
+	}
+}
+
+// amendmentRole returns the confirmation bit of an address; sender and receiver take part,
+// the arbitrator only confirms changes that replace it.
+func amendmentRole(address string, roles []string, changes []string) uint8 {
+	switch address {
+	case roles[0]:
+		return 1
+	case roles[1]:
+		return 2
+	case roles[2]:
+		if changesArbitrator(changes) {
+			return 4
+		}
+	}
+	sdk.Abort("only sender and receiver can amend")
+	return 0
+}
+
+// requiredConfirmations returns the confirmation bits an amendment needs to apply.
+func requiredConfirmations(changes []string) uint8 {
+	if changesArbitrator(changes) {
+		return 7
+	}
+	return 3
+}
+
+// changesArbitrator reports whether the changes replace the arbitrator.
+func changesArbitrator(changes []string) bool {
+	for _, c := range changes {
+		if strings.HasPrefix(c, "arb=") {
+			return true
+		}
+	}
+	return false
+}
+
+// applyAmendment validates the changes against the escrow and, if apply is set, persists them.
+// Amount reductions apply to the amount still held (after partial releases), must cover what the
+// escrow still owes and are refunded. All decisions reset since the terms changed.
+func applyAmendment(escrowID uint64, changes []string, roles []string, apply bool) {
+	amount, asset := loadReward(escrowID)
+	newAmount := amount
+	name := ""
+	to, arb := roles[1], roles[2]
+	var deadline uint64
+	seen := map[string]bool{}
+
+	for _, c := range changes {
+		key, value, found := strings.Cut(c, "=")
+		if !found || value == "" {
+			sdk.Abort("invalid change: expected key=value")
+		}
+		if seen[key] {
+			sdk.Abort("duplicate change: " + key)
+		}
+		seen[key] = true
+		switch key {
+		case "am":
+			v, err := strconv.ParseUint(value, 10, 64)
+			if err != nil || v == 0 || v >= amount {
+				sdk.Abort("invalid amount: must be a milli amount >0 below the escrowed amount")
+			}
+			if v < minimumAmount(escrowID) {
+				sdk.Abort("invalid amount: below the open units or claimed deductions")
+			}
+			newAmount = v
+		case "n":
+			if len(value) > maxNameLength {
+				sdk.Abort("name too long")
+			}
+			name = value
+		case "to":
+			if loadBondTerms(escrowID) != nil {
+				sdk.Abort("receiver of a bonded escrow cannot change")
+			}
+			to = value
+		case "arb":
+			arb = value
+		case "dl":
+			if !hasActiveDeadline(escrowID) {
+				sdk.Abort("escrow has no deadline to amend")
+			}
+			v, err := strconv.ParseUint(value, 10, 64)
+			if err != nil || v <= currentBlockHeight() {
+				sdk.Abort("invalid deadline: must be a future block height")
+			}
+			deadline = v
+		default:
+			sdk.Abort("unknown change: " + key)
+		}
+	}
+	validateParties(escrowID, roles[0], to, arb)
+	if !apply {
+		return
+	}
+
+	if newAmount < amount {
+		refundToSender(escrowID, amount-newAmount, asset, roles[0])
+		saveEscrowReward(escrowID, newAmount, asset)
+	}
+	if name != "" {
+		sdk.StateSetObject(strconv.FormatUint(escrowID, 10), name) // not saveEscrowBase: keeps the counter
+	}
+	if to != roles[1] || arb != roles[2] {
+		saveEscrowParties(escrowID, roles[0]+"|"+to+"|"+arb)
+	}
+	if to != roles[1] {
+		sdk.StateDeleteObject(strconv.FormatUint(escrowID, 10) + "|fw") // forwarding belongs to the old receiver
+		deleteAssignment(escrowID)
+		deletePayout(escrowID, 1)
+		resetCallbackConsent(escrowID)
+	}
+	if deadline > 0 {
+		saveEscrowDeadline(escrowID, deadline)
+	}
+
+	saveEscrowDecisions(escrowID, []uint8{DecisionUnset, DecisionUnset, DecisionUnset})
+	for _, b := range loadBackers(escrowID) {
+		if loadFunderDecision(escrowID, b) != DecisionUnset {
+			saveFunderDecision(escrowID, b, DecisionUnset)
+		}
+	}
+}
+
+// minimumAmount returns the lowest amount an escrow can be reduced to by amendments or partial releases:
+// the price of the units of a metered escrow not confirmed yet, or the deductions claimed against a deposit.
+func minimumAmount(escrowID uint64) uint64 {
+	switch loadKind(escrowID) {
+	case KindMetered:
+		terms := loadUnitTerms(escrowID)
+		return (terms.Cap - terms.Confirmed) * terms.Price
+	case KindDeposit:
+		var claimed uint64
+		for _, c := range loadClaims(escrowID) {
+			if c.State == ClaimRuled {
+				claimed += c.Awarded
+			} else {
+				claimed += c.Amount
+			}
+		}
+		return claimed
+	}
+	return 0
+}
+
+// hasActiveDeadline reports whether an active escrow still runs against its deadline:
+// the claim deadline of a deposit or the expiry of a metered escrow.
+// Crowdfund and invoice deadlines only apply while funding.
+func hasActiveDeadline(escrowID uint64) bool {
+	switch loadKind(escrowID) {
+	case KindDeposit, KindMetered:
+		return true
+	}
+	return false
+}
+
+// nextAmendmentID returns and increments the amendment counter of an escrow.
+func nextAmendmentID(escrowID uint64) uint64 {
+	key := strconv.FormatUint(escrowID, 10) + "|an"
+	var id uint64
+	if ptr := sdk.StateGetObject(key); ptr != nil && *ptr != "" {
+		id = StringToUInt64(ptr)
+	}
+	sdk.StateSetObject(key, strconv.FormatUint(id+1, 10))
+	return id
+}
+
+// saveAmendment stores id|proposer|confirmed|changes... of a pending amendment.
+func saveAmendment(escrowID uint64, a amendment) {
+	key := strconv.FormatUint(escrowID, 10) + "|am"
+	buf := make([]byte, 0, 64+len(a.Proposer))
+	buf = strconv.AppendUint(buf, a.ID, 10)
+	buf = append(buf, '|')
+	buf = append(buf, a.Proposer...)
+	buf = append(buf, '|')
+	buf = strconv.AppendUint(buf, uint64(a.Confirmed), 10)
+	for _, c := range a.Changes {
+		buf = append(buf, '|')
+		buf = append(buf, c...)
+	}
+	sdk.StateSetObject(key, string(buf))
+}
+
+// loadAmendment retrieves the pending amendment of an escrow; nil if there is none.
+func loadAmendment(escrowID uint64) *amendment {
+	key := strconv.FormatUint(escrowID, 10) + "|am"
+	ptr := sdk.StateGetObject(key)
+	if ptr == nil || *ptr == "" {
+		return nil
+	}
+	parts := strings.Split(*ptr, "|")
+	if len(parts) < 4 {
+		sdk.Abort("invalid amendment data")
+	}
+	return &amendment{
+		ID:        StringToUInt64(&parts[0]),
+		Proposer:  parts[1],
+		Confirmed: uint8(StringToUInt64(&parts[2])),
+		Changes:   parts[3:],
+	}
+}
+
+// deleteAmendment removes the pending amendment of an escrow.
+func deleteAmendment(escrowID uint64) {
+	sdk.StateDeleteObject(strconv.FormatUint(escrowID, 10) + "|am")
+}
+
+// EmitAmendmentProposedEvent emits an event for a proposed amendment.
+func EmitAmendmentProposedEvent(escrowID uint64, proposalID uint64, address string, changes string, txID string) {
+	emitEvent("ap", map[string]string{
+		"id": strconv.FormatUint(escrowID, 10),
+		"p":  strconv.FormatUint(proposalID, 10),
+		"a":  address,
+		"ch": changes,
+	}, txID)
+}
+
+// EmitAmendmentConfirmedEvent emits an event for a confirmation of an amendment.
+func EmitAmendmentConfirmedEvent(escrowID uint64, proposalID uint64, address string, txID string) {
+	emitEvent("ac", map[string]string{
+		"id": strconv.FormatUint(escrowID, 10),
+		"p":  strconv.FormatUint(proposalID, 10),
+		"a":  address,
+	}, txID)
+}
+
+// EmitAmendmentAppliedEvent emits an event once an amendment is applied.
+func EmitAmendmentAppliedEvent(escrowID uint64, proposalID uint64, txID string) {
+	emitEvent("aa", map[string]string{
+		"id": strconv.FormatUint(escrowID, 10),
+		"p":  strconv.FormatUint(proposalID, 10),
+	}, txID)
+}
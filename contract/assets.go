@@ -0,0 +1,149 @@
+package main
+
+import (
+	"okinoko_escrow/sdk"
+	"strconv"
+	"strings"
+)
+
+// =====================
+// Assets
+// =====================
+
+// tokenPrefix marks assets issued by another VSC contract (token:<contractId>).
+const tokenPrefix = "token:"
+
+// contractAddressPrefix is the address prefix of VSC contracts (sdk.AddressDomainContract).
+const contractAddressPrefix = "contract:"
+
+// tokenSuccess is the result a token contract returns for a completed transfer or transferFrom.
+const tokenSuccess = "true"
+
+// tokenContract returns the contract ID of a token asset; empty for native assets and malformed IDs.
+func tokenContract(asset string) string {
+	id, found := strings.CutPrefix(asset, tokenPrefix)
+	if !found || strings.ContainsAny(id, "|,:") {
+		return ""
+	}
+	return id
+}
+
+// isTokenAsset reports whether an asset is a contract token.
+func isTokenAsset(asset string) bool {
+	return tokenContract(asset) != ""
+}
+
+// Asset operations checked by requireAssetOp.
+const (
+	// AssetOpDraw pulls funds from the caller into the escrow.
+	AssetOpDraw = "draw"
+	// AssetOpTransfer moves funds from the escrow to a VSC address.
+	AssetOpTransfer = "transfer"
+	// AssetOpWithdraw unmaps funds from the escrow to a Hive L1 account.
+	AssetOpWithdraw = "withdraw"
+)
+
+// requireAssetOp aborts with the reason why an operation is not supported for an asset.
+// Liquid native assets support all operations. Staked assets are drawn from and transferred as
+// the same balance type within VSC but cannot be withdrawn. Contract tokens are drawn and
+// transferred by their token contract and cannot be withdrawn either.
+func requireAssetOp(asset string, op string) {
+	if !isValidAsset(asset) {
+		sdk.Abort("asset not supported: " + asset)
+	}
+	switch op {
+	case AssetOpDraw, AssetOpTransfer:
+	case AssetOpWithdraw:
+		requireWithdrawable(asset)
+	default:
+		sdk.Abort("unknown asset operation: " + op)
+	}
+}
+
+// isStakedAsset reports whether an asset is held as HBD savings or HIVE consensus stake.
+func isStakedAsset(asset string) bool {
+	return asset == sdk.AssetHbdSavings.String() || asset == sdk.AssetHiveCons.String()
+}
+
+// isWithdrawable reports whether payouts in an asset can be unmapped to Hive L1.
+func isWithdrawable(asset string) bool {
+	return !isTokenAsset(asset) && !isStakedAsset(asset)
+}
+
+// requireWithdrawable aborts with the reason why payouts in an asset cannot be unmapped to Hive L1.
+func requireWithdrawable(asset string) {
+	if isTokenAsset(asset) {
+		sdk.Abort("contract tokens cannot be withdrawn to Hive L1")
+	}
+	if isStakedAsset(asset) {
+		sdk.Abort(asset + " cannot be withdrawn to Hive L1, only transferred")
+	}
+}
+
+// drawFunds pulls an amount (milli) of an asset from the caller into the escrow contract.
+// HiveDraw draws from msg.caller, so a calling contract funds the escrow from its own balance
+// with the intents it forwards, matching actingAddress.
+// Contract tokens are pulled with transferFrom (from|to|amount) against the allowance the payer
+// granted this contract on the token contract beforehand.
+func drawFunds(amount uint64, asset string) {
+	requireAssetOp(asset, AssetOpDraw)
+	id := tokenContract(asset)
+	if id == "" {
+		sdk.HiveDraw(int64(amount), sdk.Asset(asset))
+		return
+	}
+	payer := actingAddress()
+	self := selfAddress()
+	if tokenAllowance(id, *payer, self) < amount {
+		sdk.Abort("token allowance below the amount on contract " + id)
+	}
+	requireTokenSuccess(id, "transferFrom", *payer+"|"+self+"|"+strconv.FormatUint(amount, 10))
+}
+
+// sendFunds transfers an amount (milli) of an asset from the escrow contract to an address.
+// Contract tokens are pushed with transfer (to|amount).
+func sendFunds(to string, amount uint64, asset string) {
+	requireAssetOp(asset, AssetOpTransfer)
+	id := tokenContract(asset)
+	if id == "" {
+		sdk.HiveTransfer(sdk.Address(to), int64(amount), sdk.Asset(asset))
+		return
+	}
+	requireTokenSuccess(id, "transfer", to+"|"+strconv.FormatUint(amount, 10))
+}
+
+// tokenAllowance returns the amount (milli) a token contract allows the spender to pull from the owner (owner|spender).
+func tokenAllowance(id string, owner string, spender string) uint64 {
+	ret := callToken(id, "allowance", owner+"|"+spender)
+	allowance, err := strconv.ParseUint(ret, 10, 64)
+	if err != nil {
+		sdk.Abort("invalid allowance from token contract " + id)
+	}
+	return allowance
+}
+
+// requireTokenSuccess calls a token transfer and aborts the whole transaction unless the token
+// contract confirms it with tokenSuccess, so no funds are booked that the token contract did not move.
+func requireTokenSuccess(id string, method string, payload string) {
+	if ret := callToken(id, method, payload); ret != tokenSuccess {
+		sdk.Abort("token " + method + " failed on contract " + id + ": " + ret)
+	}
+}
+
+// callToken calls a token contract without intents and returns its result.
+func callToken(id string, method string, payload string) string {
+	ret := sdk.ContractCall(id, method, payload, nil)
+	if ret == nil {
+		sdk.Abort("token " + method + " returned no result on contract " + id)
+	}
+	return *ret
+}
+
+// selfAddress returns the contract address of this escrow contract.
+func selfAddress() string {
+	id := sdk.GetEnvKey("contract.id")
+	if id == nil || *id == "" {
+		sdk.Abort("contract id not available")
+	}
+	return contractAddressPrefix + *id
+}
@@ -0,0 +1,249 @@
+package main
+
+import (
+	"okinoko_escrow/sdk"
+	"strconv"
+	"strings"
+)
+
+// =====================
+// Receiver Assignment
+// =====================
+
+// EscrowAssignment describes a pending assignment of the receiver position.
+type EscrowAssignment struct {
+	To      string `json:"t"`
+	Height  uint64 `json:"h"`
+	Consent uint8  `json:"c"` // bit per consent (1=from, 2=arb)
+}
+
+// parseAssignOption parses the assignment option value (c for consent, o:blocks for an objection window).
+func parseAssignOption(value string) uint64 {
+	if value == "c" {
+		return 0
+	}
+	blocksStr, found := strings.CutPrefix(value, "o:")
+	blocks, err := strconv.ParseUint(blocksStr, 10, 64)
+	if !found || err != nil || blocks == 0 {
+		sdk.Abort("invalid assignment option: expected c or o:blocks")
+	}
+	return blocks
+}
+
+// AssignReceiver lets the receiver assign its position to a new address (EscrowID|NewReceiver).
+// By default sender and arbitrator must consent; escrows created with an objection window
+// complete the assignment once the window passed without objection.
+//
+//go:wasmexport e_assign
+func AssignReceiver(payload *string) *string {
+	if payload == nil || *payload == "" {
+		sdk.Abort("input CSV is nil or empty")
+	}
+	idStr, newTo, found := strings.Cut(*payload, "|")
+	if !found || newTo == "" {
+		sdk.Abort("invalid CSV format: expected EscrowID|NewReceiver")
+	}
+	escrowID := StringToUInt64(&idStr)
+	requireAssignable(escrowID)
+
+	sender := actingAddress()
+	roles := loadRoles(escrowID)
+	if *sender != roles[1] {
+		sdk.Abort("only the receiver can assign")
+	}
+	if newTo == roles[0] || newTo == roles[1] || newTo == roles[2] {
+		sdk.Abort("new receiver must be a new address")
+	}
+	validateParties(escrowID, roles[0], newTo, roles[2])
+
+	saveAssignment(escrowID, EscrowAssignment{To: newTo, Height: currentBlockHeight()})
+	txID := sdk.GetEnvKey("tx.id")
+	EmitAssignmentEvent(escrowID, roles[1], newTo, *txID)
+	return nil
+}
+
+// RespondAssignment lets sender or arbitrator consent (y) to or object (n) against a pending assignment.
+// An objection cancels the assignment.
+//
+//go:wasmexport e_assign_respond
+func RespondAssignment(payload *string) *string {
+	if payload == nil || *payload == "" {
+		sdk.Abort("input CSV is nil or empty")
+	}
+	idStr, answer, found := strings.Cut(*payload, "|")
+	if !found || (answer != "y" && answer != "n") {
+		sdk.Abort("invalid CSV format: expected EscrowID|y/n")
+	}
+	escrowID := StringToUInt64(&idStr)
+	requireAssignable(escrowID)
+	as := loadAssignment(escrowID)
+	if as == nil {
+		sdk.Abort("no pending assignment")
+	}
+
+	sender := actingAddress()
+	roles := loadRoles(escrowID)
+	var bit uint8
+	switch *sender {
+	case roles[0]:
+		bit = 1
+	case roles[2]:
+		bit = 2
+	default:
+		sdk.Abort("only sender and arbitrator can respond")
+	}
+
+	txID := sdk.GetEnvKey("tx.id")
+	if answer == "n" {
+		deleteAssignment(escrowID)
+		EmitAssignmentObjectedEvent(escrowID, *sender, *txID)
+		return nil
+	}
+	as.Consent |= bit
+	saveAssignment(escrowID, *as)
+	if assignmentComplete(escrowID, as) {
+		applyAssignment(escrowID, as.To, roles, *txID)
+	}
+	return nil
+}
+
+// CompleteAssignment applies a pending assignment whose objection window passed; callable by anyone.
+//
+//go:wasmexport e_assign_complete
+func CompleteAssignment(id *string) *string {
+	escrowID := StringToUInt64(id)
+	requireAssignable(escrowID)
+	as := loadAssignment(escrowID)
+	if as == nil {
+		sdk.Abort("no pending assignment")
+	}
+	if !assignmentComplete(escrowID, as) {
+		sdk.Abort("assignment not approved yet")
+	}
+	txID := sdk.GetEnvKey("tx.id")
+	applyAssignment(escrowID, as.To, loadRoles(escrowID), *txID)
+	return nil
+}
+
+// =====================
+// Assignment Helpers
+// =====================
+
+// requireAssignable aborts unless the receiver position of the escrow can be assigned.
+// A bond secures the performance of the receiver who posted it and is paid out to that receiver,
+// so bonded escrows are never assigned.
+func requireAssignable(escrowID uint64) {
+	if closed, _ := loadEscrowOutcome(escrowID, loadDecisions(escrowID)); closed {
+		sdk.Abort("escrow already closed")
+	}
+	if loadKind(escrowID) == KindWager {
+		sdk.Abort("wagers cannot be assigned")
+	}
+	if loadBondTerms(escrowID) != nil {
+		sdk.Abort("escrows with a bond cannot be assigned")
+	}
+	if len(loadEscrowReceivers(escrowID)) > 0 {
+		sdk.Abort("escrows with multiple receivers cannot be assigned")
+	}
+}
+
+// assignmentComplete reports whether both consented or, with an objection window, the window passed.
+func assignmentComplete(escrowID uint64, as *EscrowAssignment) bool {
+	if as.Consent == 3 {
+		return true
+	}
+	window := loadAssignWindow(escrowID)
+	return window > 0 && currentBlockHeight() > as.Height+window
+}
+
+// applyAssignment rewrites the parties with the new receiver and drops state of the old receiver.
+// The new receiver is checked again since refund address and backers may have changed meanwhile.
+func applyAssignment(escrowID uint64, newTo string, roles []string, txID string) {
+	validateParties(escrowID, roles[0], newTo, roles[2])
+	saveEscrowParties(escrowID, roles[0]+"|"+newTo+"|"+roles[2])
+	decs := loadDecisions(escrowID)
+	decs[1] = DecisionUnset
+	saveEscrowDecisions(escrowID, decs)
+	deleteOffer(escrowID, 1)
+	deletePayout(escrowID, 1)
+	sdk.StateDeleteObject(strconv.FormatUint(escrowID, 10) + "|fw")
+	deleteAmendment(escrowID) // carries the confirmation of the old receiver
+	resetCallbackConsent(escrowID)
+	deleteAssignment(escrowID)
+	EmitAssignedEvent(escrowID, roles[1], newTo, txID)
+}
+
+// saveAssignWindow stores the objection window (blocks) of an escrow.
+func saveAssignWindow(escrowID uint64, blocks uint64) {
+	sdk.StateSetObject(strconv.FormatUint(escrowID, 10)+"|ax", strconv.FormatUint(blocks, 10))
+}
+
+// loadAssignWindow retrieves the objection window of an escrow; 0 requires consent.
+func loadAssignWindow(escrowID uint64) uint64 {
+	ptr := sdk.StateGetObject(strconv.FormatUint(escrowID, 10) + "|ax")
+	if ptr == nil || *ptr == "" {
+		return 0
+	}
+	return StringToUInt64(ptr)
+}
+
+// saveAssignment stores to|height|consent of a pending assignment.
+func saveAssignment(escrowID uint64, as EscrowAssignment) {
+	key := strconv.FormatUint(escrowID, 10) + "|as"
+	buf := make([]byte, 0, 32+len(as.To))
+	buf = append(buf, as.To...)
+	buf = append(buf, '|')
+	buf = strconv.AppendUint(buf, as.Height, 10)
+	buf = append(buf, '|')
+	buf = strconv.AppendUint(buf, uint64(as.Consent), 10)
+	sdk.StateSetObject(key, string(buf))
+}
+
+// loadAssignment retrieves the pending assignment of an escrow; nil if there is none.
+func loadAssignment(escrowID uint64) *EscrowAssignment {
+	key := strconv.FormatUint(escrowID, 10) + "|as"
+	ptr := sdk.StateGetObject(key)
+	if ptr == nil || *ptr == "" {
+		return nil
+	}
+	parts := strings.Split(*ptr, "|")
+	if len(parts) != 3 {
+		sdk.Abort("invalid assignment data")
+	}
+	return &EscrowAssignment{
+		To:      parts[0],
+		Height:  StringToUInt64(&parts[1]),
+		Consent: uint8(StringToUInt64(&parts[2])),
+	}
+}
+
+// deleteAssignment removes the pending assignment of an escrow.
+func deleteAssignment(escrowID uint64) {
+	sdk.StateDeleteObject(strconv.FormatUint(escrowID, 10) + "|as")
+}
+
+// EmitAssignmentEvent emits an event for a proposed assignment of the receiver position.
+func EmitAssignmentEvent(escrowID uint64, oldTo string, newTo string, txID string) {
+	emitEvent("as", map[string]string{
+		"id": strconv.FormatUint(escrowID, 10),
+		"o":  oldTo,
+		"n":  newTo,
+	}, txID)
+}
+
+// EmitAssignmentObjectedEvent emits an event for an objection against a pending assignment.
+func EmitAssignmentObjectedEvent(escrowID uint64, address string, txID string) {
+	emitEvent("ao", map[string]string{
+		"id": strconv.FormatUint(escrowID, 10),
+		"a":  address,
+	}, txID)
+}
+
+// EmitAssignedEvent emits an event once the receiver position moved from the old to the new holder.
+func EmitAssignedEvent(escrowID uint64, oldTo string, newTo string, txID string) {
+	emitEvent("ad", map[string]string{
+		"id": strconv.FormatUint(escrowID, 10),
+		"o":  oldTo,
+		"n":  newTo,
+	}, txID)
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"okinoko_escrow/sdk"
+	"strconv"
+	"strings"
+)
+
+// =====================
+// Performance Bonds
+// =====================
+
+// maxBasisPoints represents 100% in basis points.
+const maxBasisPoints = 10000
+
+// EscrowBond describes the receiver's performance bond of an escrow.
+type EscrowBond struct {
+	Amount float64 `json:"am"`
+	Share  uint64  `json:"sh"`
+	Posted bool    `json:"ps"`
+}
+
+// bondTerms holds the stored bond amount (milli), the sender share on refund (bps) and whether it was posted.
+type bondTerms struct {
+	Amount uint64
+	Share  uint64
+	Posted bool
+}
+
+// parseBondOption parses the bond option value (amount[:shareBps]).
+// Without a share, the full bond goes to the sender on refund.
+func parseBondOption(value string) (uint64, uint64) {
+	amountStr, shareStr, hasShare := strings.Cut(value, ":")
+	amount, err := strconv.ParseUint(amountStr, 10, 64)
+	if err != nil || amount == 0 {
+		sdk.Abort("invalid bond: amount must be a milli amount >0")
+	}
+	share := uint64(maxBasisPoints)
+	if hasShare {
+		share, err = strconv.ParseUint(shareStr, 10, 64)
+		if err != nil || share > maxBasisPoints {
+			sdk.Abort("invalid bond share: must be 0-10000 basis points")
+		}
+	}
+	return amount, share
+}
+
+// PostBond deposits the receiver's bond from a transfer.allow intent and activates the escrow.
+//
+//go:wasmexport e_bond
+func PostBond(id *string) *string {
+	escrowID := StringToUInt64(id)
+	terms := loadBondTerms(escrowID)
+	if terms == nil {
+		sdk.Abort("escrow has no bond")
+	}
+	if terms.Posted {
+		sdk.Abort("bond already posted")
+	}
+	if closed, _ := loadEscrowOutcome(escrowID, loadDecisions(escrowID)); closed {
+		sdk.Abort("escrow already closed")
+	}
+
+	sender := actingAddress()
+	roles := loadRoles(escrowID)
+	if *sender != roles[1] {
+		sdk.Abort("only the receiver can post the bond")
+	}
+
+	_, asset := loadReward(escrowID)
+	ta := GetFirstTransferAllow(sdk.GetEnv().Intents)
+	if ta == nil {
+		sdk.Abort("intent needed")
+	}
+	if ta.Token.String() != asset {
+		sdk.Abort("intent asset does not match escrow asset")
+	}
+	if ta.LimitMilli < terms.Amount {
+		sdk.Abort("intent below bond amount")
+	}
+
+	drawFunds(terms.Amount, ta.Token.String())
+	terms.Posted = true
+	saveBondTerms(escrowID, *terms)
+	saveEscrowStatus(escrowID, StatusActive)
+
+	txID := sdk.GetEnvKey("tx.id")
+	EmitBondPostedEvent(escrowID, *sender, float64(terms.Amount)/1000, *txID)
+	return nil
+}
+
+// settleBond pays out a posted bond for the outcome and returns the close event details.
+// On release the bond returns to the receiver; on refund the configured share goes to the sender.
+func settleBond(escrowID uint64, outcome uint8, roles []string, asset string) map[string]string {
+	terms := loadBondTerms(escrowID)
+	if terms == nil || !terms.Posted {
+		return nil
+	}
+
+	toSender := uint64(0)
+	if outcome == DecisionRefund {
+		toSender = mulDiv(terms.Amount, terms.Share, maxBasisPoints)
+	}
+	toReceiver := terms.Amount - toSender
+	if toSender > 0 {
+		paySender(escrowID, roles[0], toSender, asset)
+	}
+	if toReceiver > 0 {
+		payParty(escrowID, 1, roles[1], toReceiver, asset)
+	}
+	return map[string]string{
+		"bf": strconv.FormatFloat(float64(toSender)/1000, 'f', -1, 64),
+		"bt": strconv.FormatFloat(float64(toReceiver)/1000, 'f', -1, 64),
+	}
+}
+
+// saveEscrowBond stores new bond terms for an escrow.
+func saveEscrowBond(escrowID uint64, amount uint64, share uint64) {
+	saveBondTerms(escrowID, bondTerms{Amount: amount, Share: share})
+}
+
+// saveBondTerms stores amount|share|posted of an escrow bond.
+func saveBondTerms(escrowID uint64, terms bondTerms) {
+	key := strconv.FormatUint(escrowID, 10) + "|bo"
+	buf := make([]byte, 0, 48)
+	buf = strconv.AppendUint(buf, terms.Amount, 10)
+	buf = append(buf, '|')
+	buf = strconv.AppendUint(buf, terms.Share, 10)
+	buf = append(buf, '|')
+	if terms.Posted {
+		buf = append(buf, '1')
+	} else {
+		buf = append(buf, '0')
+	}
+	sdk.StateSetObject(key, string(buf))
+}
+
+// loadBondTerms retrieves the bond terms of an escrow; nil if it has no bond.
+func loadBondTerms(escrowID uint64) *bondTerms {
+	key := strconv.FormatUint(escrowID, 10) + "|bo"
+	ptr := sdk.StateGetObject(key)
+	if ptr == nil || *ptr == "" {
+		return nil
+	}
+	parts := strings.Split(*ptr, "|")
+	if len(parts) != 3 {
+		sdk.Abort("invalid bond data")
+	}
+	return &bondTerms{
+		Amount: StringToUInt64(&parts[0]),
+		Share:  StringToUInt64(&parts[1]),
+		Posted: parts[2] == "1",
+	}
+}
+
+// loadEscrowBond returns the bond view of an escrow; nil if it has no bond.
+func loadEscrowBond(escrowID uint64) *EscrowBond {
+	terms := loadBondTerms(escrowID)
+	if terms == nil {
+		return nil
+	}
+	return &EscrowBond{
+		Amount: float64(terms.Amount) / 1000,
+		Share:  terms.Share,
+		Posted: terms.Posted,
+	}
+}
+
+// EmitBondPostedEvent emits an event for a posted receiver bond.
+func EmitBondPostedEvent(escrowID uint64, address string, amount float64, txID string) {
+	emitEvent("bo", map[string]string{
+		"id": strconv.FormatUint(escrowID, 10),
+		"a":  address,
+		"am": strconv.FormatFloat(amount, 'f', -1, 64),
+	}, txID)
+}
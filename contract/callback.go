@@ -0,0 +1,165 @@
+package main
+
+import (
+	"okinoko_escrow/sdk"
+	"strconv"
+	"strings"
+)
+
+// =====================
+// Close Callbacks
+// =====================
+
+const (
+	// CallbackLog queues the callback at close; anyone delivers it with e_notify, so a failing callback never blocks the close.
+	CallbackLog = "l"
+	// CallbackRevert calls the callback within the closing transaction; a failing callback reverts the close.
+	// It can hold back the receiver's payout, so it only applies once the receiver consented.
+	CallbackRevert = "r"
+)
+
+// EscrowCallback describes the contract notified when an escrow closes.
+type EscrowCallback struct {
+	Contract  string `json:"c"`
+	Method    string `json:"m"`
+	Policy    string `json:"p"`
+	Consented bool   `json:"ok,omitempty"`
+}
+
+// parseCallbackOption parses the callback option value (contractId:method[:policy]).
+// The policy defaults to log.
+func parseCallbackOption(value string) *EscrowCallback {
+	parts := strings.Split(value, ":")
+	if len(parts) < 2 || len(parts) > 3 || parts[0] == "" || parts[1] == "" {
+		sdk.Abort("invalid callback: expected contractId:method[:r|l]")
+	}
+	cb := &EscrowCallback{Contract: parts[0], Method: parts[1], Policy: CallbackLog}
+	if len(parts) == 3 {
+		if parts[2] != CallbackLog && parts[2] != CallbackRevert {
+			sdk.Abort("invalid callback policy: must be r/l")
+		}
+		cb.Policy = parts[2]
+	}
+	return cb
+}
+
+// ConsentCallback lets the receiver accept the revert policy of the close callback (EscrowID).
+// Until then the callback is queued like with the log policy.
+//
+//go:wasmexport e_callback_consent
+func ConsentCallback(id *string) *string {
+	escrowID := StringToUInt64(id)
+	requireOpenEscrow(escrowID)
+	cb := loadEscrowCallback(escrowID)
+	if cb == nil || cb.Policy != CallbackRevert {
+		sdk.Abort("escrow has no revert callback")
+	}
+	if *actingAddress() != loadRoles(escrowID)[1] {
+		sdk.Abort("only the receiver can consent")
+	}
+	cb.Consented = true
+	saveEscrowCallback(escrowID, *cb)
+	return nil
+}
+
+// NotifyClose delivers the queued close callback of an escrow with the log policy; callable by anyone.
+// A failing callback only fails this call, the close stands and the callback stays queued.
+//
+//go:wasmexport e_notify
+func NotifyClose(id *string) *string {
+	escrowID := StringToUInt64(id)
+	cb := loadEscrowCallback(escrowID)
+	if cb == nil {
+		sdk.Abort("escrow has no callback")
+	}
+	key := strconv.FormatUint(escrowID, 10) + "|cn"
+	payload := sdk.StateGetObject(key)
+	if payload == nil || *payload == "" {
+		sdk.Abort("no pending callback")
+	}
+	callCallback(*cb, *payload)
+	sdk.StateDeleteObject(key)
+
+	txID := sdk.GetEnvKey("tx.id")
+	EmitCallbackEvent(escrowID, *cb, *txID)
+	return nil
+}
+
+// notifyClose handles the close callback of an escrow with the outcome and payouts as JSON.
+// The amount is everything paid out of the escrow: the payout at close plus earlier partial releases.
+// A consented revert policy calls it right away, otherwise it is queued for e_notify.
+func notifyClose(escrowID uint64, outcome uint8, paid uint64, details map[string]string, txID string) {
+	cb := loadEscrowCallback(escrowID)
+	if cb == nil {
+		return
+	}
+	_, as := loadReward(escrowID)
+	payload := map[string]string{
+		"id": strconv.FormatUint(escrowID, 10),
+		"o":  friendlyOutcome(outcome),
+		"am": strconv.FormatFloat(float64(paid+loadReleased(escrowID))/1000, 'f', -1, 64),
+		"as": as,
+	}
+	for k, v := range details {
+		payload[k] = v
+	}
+
+	data := ToJSON(payload, "callback payload")
+
+	if cb.Policy != CallbackRevert || !cb.Consented {
+		sdk.StateSetObject(strconv.FormatUint(escrowID, 10)+"|cn", data)
+		return
+	}
+	callCallback(*cb, data)
+	EmitCallbackEvent(escrowID, *cb, txID)
+}
+
+// callCallback calls a callback contract and aborts if the call fails. Depending on the host a failing
+// callee either traps the whole transaction or returns no result; both abort the calling transaction.
+func callCallback(cb EscrowCallback, payload string) {
+	ret := sdk.ContractCall(cb.Contract, cb.Method, payload, nil)
+	if ret == nil {
+		sdk.Abort("close callback failed on contract " + cb.Contract)
+	}
+}
+
+// saveEscrowCallback stores contract|method|policy|consent of a close callback.
+func saveEscrowCallback(escrowID uint64, cb EscrowCallback) {
+	key := strconv.FormatUint(escrowID, 10) + "|cb"
+	consent := "0"
+	if cb.Consented {
+		consent = "1"
+	}
+	sdk.StateSetObject(key, cb.Contract+"|"+cb.Method+"|"+cb.Policy+"|"+consent)
+}
+
+// resetCallbackConsent drops the receiver's consent to a revert callback once the receiver changes.
+func resetCallbackConsent(escrowID uint64) {
+	if cb := loadEscrowCallback(escrowID); cb != nil && cb.Consented {
+		cb.Consented = false
+		saveEscrowCallback(escrowID, *cb)
+	}
+}
+
+// loadEscrowCallback retrieves the close callback of an escrow; nil if it has none.
+func loadEscrowCallback(escrowID uint64) *EscrowCallback {
+	key := strconv.FormatUint(escrowID, 10) + "|cb"
+	ptr := sdk.StateGetObject(key)
+	if ptr == nil || *ptr == "" {
+		return nil
+	}
+	parts := strings.Split(*ptr, "|")
+	if len(parts) != 4 {
+		sdk.Abort("invalid callback data")
+	}
+	return &EscrowCallback{Contract: parts[0], Method: parts[1], Policy: parts[2], Consented: parts[3] == "1"}
+}
+
+// EmitCallbackEvent emits an event for a delivered close callback.
+func EmitCallbackEvent(escrowID uint64, cb EscrowCallback, txID string) {
+	emitEvent("cb", map[string]string{
+		"id": strconv.FormatUint(escrowID, 10),
+		"c":  cb.Contract,
+		"m":  cb.Method,
+	}, txID)
+}
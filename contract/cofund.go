@@ -0,0 +1,99 @@
+package main
+
+import (
+	"okinoko_escrow/sdk"
+	"strconv"
+)
+
+// =====================
+// Co-Funded Escrows
+// =====================
+
+// parseCofundOption parses the co-funding option value (1 enables co-funding).
+func parseCofundOption(value string) bool {
+	switch value {
+	case "1":
+		return true
+	case "0":
+		return false
+	default:
+		sdk.Abort("invalid co-funding option: must be 0/1")
+	}
+	return false
+}
+
+// Cofund adds the caller's transfer.allow intent to a co-funded escrow.
+// Co-funders vote on the sender side, weighted by their contribution.
+//
+//go:wasmexport e_cofund
+func Cofund(id *string) *string {
+	escrowID := StringToUInt64(id)
+	if loadKind(escrowID) != KindCofunded {
+		sdk.Abort("escrow is not co-funded")
+	}
+	if closed, _ := loadEscrowOutcome(escrowID, loadDecisions(escrowID)); closed {
+		sdk.Abort("escrow already closed")
+	}
+
+	sender := actingAddress()
+	roles := loadRoles(escrowID)
+	if *sender == roles[1] || *sender == roles[2] {
+		sdk.Abort("receiver and arbitrator cannot co-fund")
+	}
+
+	amount, asset := loadReward(escrowID)
+	ta := GetFirstTransferAllow(sdk.GetEnv().Intents)
+	if ta == nil {
+		sdk.Abort("intent needed")
+	}
+	if ta.Token.String() != asset {
+		sdk.Abort("intent asset does not match escrow asset")
+	}
+
+	drawFunds(ta.LimitMilli, ta.Token.String())
+	addBackerContribution(escrowID, *sender, ta.LimitMilli)
+	saveEscrowReward(escrowID, amount+ta.LimitMilli, asset)
+
+	// New weight may change the aggregated sender decision.
+	decs := loadDecisions(escrowID)
+	decs[0] = aggregateFunderDecision(escrowID)
+	saveEscrowDecisions(escrowID, decs)
+
+	txID := sdk.GetEnvKey("tx.id")
+	EmitContributionEvent(escrowID, *sender, float64(ta.LimitMilli)/1000, *txID)
+	processEscrowOutcome(escrowID, decs, *txID)
+	return nil
+}
+
+// saveFunderDecision stores the decision of a single co-funder.
+func saveFunderDecision(escrowID uint64, funder string, decision uint8) {
+	key := strconv.FormatUint(escrowID, 10) + "|v|" + funder
+	sdk.StateSetObject(key, string([]byte{decision}))
+}
+
+// loadFunderDecision retrieves the decision of a single co-funder.
+func loadFunderDecision(escrowID uint64, funder string) uint8 {
+	key := strconv.FormatUint(escrowID, 10) + "|v|" + funder
+	ptr := sdk.StateGetObject(key)
+	if ptr == nil || *ptr == "" {
+		return DecisionUnset
+	}
+	return (*ptr)[0]
+}
+
+// aggregateFunderDecision returns the decision backed by more than half of the funded weight.
+func aggregateFunderDecision(escrowID uint64) uint8 {
+	var total uint64
+	weights := [3]uint64{}
+	for _, f := range loadBackers(escrowID) {
+		c := loadBackerContribution(escrowID, f)
+		total += c
+		weights[loadFunderDecision(escrowID, f)] += c
+	}
+	for _, d := range []uint8{DecisionRefund, DecisionRelease} {
+		if weights[d]*2 > total {
+			return d
+		}
+	}
+	return DecisionUnset
+}
@@ -0,0 +1,328 @@
+package main
+
+import (
+	"fmt"
+	"okinoko_escrow/sdk"
+	"strconv"
+	"strings"
+)
+
+// =====================
+// Crowdfunded Escrows
+// =====================
+
+// CreateCrowdfundArgs are arguments to create a new crowdfunded escrow.
+type CreateCrowdfundArgs struct {
+	CreateEscrowArgs
+	Goal     uint64
+	Asset    string
+	Deadline uint64
+}
+
+// CsvToCreateCrowdfundArgs parses a pipe-delimited string into CreateCrowdfundArgs
+// (Name|To|Arbitrator|Goal|Asset|Deadline). Goal is given in milli units, Deadline as block height.
+func CsvToCreateCrowdfundArgs(csv *string) CreateCrowdfundArgs {
+	if csv == nil || *csv == "" {
+		sdk.Abort("input CSV is nil or empty")
+	}
+
+	parts := strings.Split(*csv, "|")
+	if len(parts) != 6 {
+		sdk.Abort("invalid CSV format: expected 6 fields (Name|To|Arbitrator|Goal|Asset|Deadline)")
+	}
+
+	goal, err := strconv.ParseUint(parts[3], 10, 64)
+	if err != nil {
+		sdk.Abort("invalid goal: must be a milli amount")
+	}
+	deadline, err := strconv.ParseUint(parts[5], 10, 64)
+	if err != nil {
+		sdk.Abort("invalid deadline: must be a block height")
+	}
+
+	return CreateCrowdfundArgs{
+		CreateEscrowArgs: CreateEscrowArgs{
+			Name:       parts[0],
+			To:         parts[1],
+			Arbitrator: parts[2],
+		},
+		Goal:     goal,
+		Asset:    parts[4],
+		Deadline: deadline,
+	}
+}
+
+// CreateCrowdfund creates an escrow that collects contributions until its goal is met.
+//
+//go:wasmexport e_create_cf
+func CreateCrowdfund(payload *string) *string {
+	input := CsvToCreateCrowdfundArgs(payload)
+	creator := actingAddress()
+
+	input.Validate(*creator)
+	if input.To == *creator {
+		sdk.Abort("receiver must differ from sender")
+	}
+	if input.Goal == 0 {
+		sdk.Abort("goal >0 needed")
+	}
+	if !isValidAsset(input.Asset) {
+		sdk.Abort("asset not supported")
+	}
+	if input.Deadline <= currentBlockHeight() {
+		sdk.Abort("deadline must be in the future")
+	}
+
+	// The reward holds the raised amount and grows with each contribution.
+	escrowID := newEscrowID()
+	initEscrow(escrowID, input.Name, *creator, input.To, input.Arbitrator, 0, input.Asset)
+	saveEscrowKind(escrowID, KindCrowdfund)
+	saveEscrowStatus(escrowID, StatusFunding)
+	saveEscrowGoal(escrowID, input.Goal)
+	saveEscrowDeadline(escrowID, input.Deadline)
+
+	txID := sdk.GetEnvKey("tx.id")
+	EmitCrowdfundCreatedEvent(
+		escrowID,
+		*creator,
+		input.To,
+		input.Arbitrator,
+		float64(input.Goal)/1000,
+		input.Asset,
+		input.Deadline,
+		*txID)
+
+	result := strconv.FormatUint(escrowID, 10)
+	return &result
+}
+
+// Contribute adds the caller's transfer.allow intent to a crowdfunded escrow.
+// Contributions beyond the remaining goal are capped.
+//
+//go:wasmexport e_contribute
+func Contribute(id *string) *string {
+	escrowID := StringToUInt64(id)
+	if loadKind(escrowID) != KindCrowdfund {
+		sdk.Abort("escrow is not a crowdfund")
+	}
+	if loadStatus(escrowID) != StatusFunding {
+		sdk.Abort("funding already completed")
+	}
+	if currentBlockHeight() > loadDeadline(escrowID) {
+		sdk.Abort("funding deadline passed")
+	}
+
+	raised, asset := loadReward(escrowID)
+	ta := GetFirstTransferAllow(sdk.GetEnv().Intents)
+	if ta == nil {
+		sdk.Abort("intent needed")
+	}
+	if ta.Token.String() != asset {
+		sdk.Abort("intent asset does not match escrow asset")
+	}
+
+	goal := loadGoal(escrowID)
+	amount := ta.LimitMilli
+	if amount > goal-raised {
+		amount = goal - raised
+	}
+	sender := actingAddress()
+	drawFunds(amount, ta.Token.String())
+
+	addBackerContribution(escrowID, *sender, amount)
+	raised += amount
+	saveEscrowReward(escrowID, raised, asset)
+
+	txID := sdk.GetEnvKey("tx.id")
+	EmitContributionEvent(escrowID, *sender, float64(amount)/1000, *txID)
+	if raised >= goal {
+		saveEscrowStatus(escrowID, StatusActive)
+		EmitEscrowFundedEvent(escrowID, float64(raised)/1000, *txID)
+	}
+	return nil
+}
+
+// WithdrawContribution returns the caller's contribution once a crowdfund missed its goal.
+//
+//go:wasmexport e_withdraw
+func WithdrawContribution(id *string) *string {
+	escrowID := StringToUInt64(id)
+	if loadKind(escrowID) != KindCrowdfund {
+		sdk.Abort("escrow is not a crowdfund")
+	}
+	if !fundingExpired(escrowID) {
+		sdk.Abort("withdrawal only possible after a missed goal")
+	}
+
+	sender := actingAddress()
+	contribution := loadBackerContribution(escrowID, *sender)
+	if contribution == 0 {
+		sdk.Abort("nothing to withdraw")
+	}
+
+	raised, asset := loadReward(escrowID)
+	saveBackerContribution(escrowID, *sender, 0)
+	saveEscrowReward(escrowID, raised-contribution, asset)
+	sendFunds(*sender, contribution, asset)
+
+	txID := sdk.GetEnvKey("tx.id")
+	EmitWithdrawalEvent(escrowID, *sender, float64(contribution)/1000, *txID)
+	return nil
+}
+
+// =====================
+// Crowdfund State
+// =====================
+
+// saveEscrowGoal stores the funding goal (milli) of a crowdfund.
+func saveEscrowGoal(escrowID uint64, goal uint64) {
+	key := strconv.FormatUint(escrowID, 10) + "|g"
+	sdk.StateSetObject(key, strconv.FormatUint(goal, 10))
+}
+
+// loadGoal retrieves the funding goal (milli) of a crowdfund.
+func loadGoal(escrowID uint64) uint64 {
+	key := strconv.FormatUint(escrowID, 10) + "|g"
+	ptr := sdk.StateGetObject(key)
+	if ptr == nil || *ptr == "" {
+		sdk.Abort(fmt.Sprintf("goal for escrow %d not found", escrowID))
+	}
+	return StringToUInt64(ptr)
+}
+
+// loadBackers retrieves the addresses of all backers in contribution order.
+func loadBackers(escrowID uint64) []string {
+	key := strconv.FormatUint(escrowID, 10) + "|b"
+	ptr := sdk.StateGetObject(key)
+	if ptr == nil || *ptr == "" {
+		return nil
+	}
+	return strings.Split(*ptr, "|")
+}
+
+// saveBackerContribution stores the contribution (milli) of a single backer.
+func saveBackerContribution(escrowID uint64, backer string, amount uint64) {
+	key := strconv.FormatUint(escrowID, 10) + "|b|" + backer
+	sdk.StateSetObject(key, strconv.FormatUint(amount, 10))
+}
+
+// loadBackerContribution retrieves the contribution (milli) of a single backer.
+func loadBackerContribution(escrowID uint64, backer string) uint64 {
+	key := strconv.FormatUint(escrowID, 10) + "|b|" + backer
+	ptr := sdk.StateGetObject(key)
+	if ptr == nil || *ptr == "" {
+		return 0
+	}
+	return StringToUInt64(ptr)
+}
+
+// addBackerContribution adds to a backer's contribution and registers new backers.
+func addBackerContribution(escrowID uint64, backer string, amount uint64) {
+	current := loadBackerContribution(escrowID, backer)
+	if current == 0 {
+		backers := loadBackers(escrowID)
+		known := false
+		for _, b := range backers {
+			if b == backer {
+				known = true
+				break
+			}
+		}
+		if !known {
+			key := strconv.FormatUint(escrowID, 10) + "|b"
+			sdk.StateSetObject(key, strings.Join(append(backers, backer), "|"))
+		}
+	}
+	saveBackerContribution(escrowID, backer, current+amount)
+}
+
+// refundBackers pays the amount back to all backers pro-rata to their contributions.
+// The rounding remainder goes to the first backer.
+func refundBackers(escrowID uint64, amount uint64, asset string) {
+	backers := loadBackers(escrowID)
+	contributions := make([]uint64, len(backers))
+	var total uint64
+	for i, b := range backers {
+		contributions[i] = loadBackerContribution(escrowID, b)
+		total += contributions[i]
+	}
+	if total == 0 {
+		sdk.Abort("no contributions to refund")
+	}
+
+	shares := make([]uint64, len(backers))
+	var paid uint64
+	for i, c := range contributions {
+		shares[i] = mulDiv(amount, c, total)
+		paid += shares[i]
+	}
+	shares[0] += amount - paid
+
+	for i, b := range backers {
+		if shares[i] > 0 {
+			sendPayout(defaultTarget(escrowID, b), shares[i], asset)
+		}
+	}
+}
+
+// fillBackers adds all backers and their contributions to an escrow view.
+func fillBackers(escrow *Escrow) {
+	for _, b := range loadBackers(escrow.ID) {
+		escrow.Backers = append(escrow.Backers, EscrowBacker{
+			Address: b,
+			Amount:  float64(loadBackerContribution(escrow.ID, b)) / 1000,
+		})
+	}
+}
+
+// fillCrowdfund adds goal, deadline and backers to an escrow view.
+// A crowdfund that missed its goal is reported as refunded.
+func fillCrowdfund(escrow *Escrow) {
+	escrow.Goal = float64(loadGoal(escrow.ID)) / 1000
+	escrow.Deadline = loadDeadline(escrow.ID)
+	fillBackers(escrow)
+	if fundingExpired(escrow.ID) {
+		escrow.Closed = true
+		escrow.Outcome = DecisionRefund
+		escrow.Status = StatusExpired
+	}
+}
+
+// =====================
+// Crowdfund Events
+// =====================
+
+// EmitCrowdfundCreatedEvent emits an event for a newly created crowdfund.
+func EmitCrowdfundCreatedEvent(escrowID uint64, fromAddress string, toAddress string, arbAddress string, goal float64, asset string, deadline uint64, txID string) {
+	EmitEscrowCreatedEvent(escrowID, fromAddress, toAddress, arbAddress, 0, asset, map[string]string{
+		"k":  KindCrowdfund,
+		"g":  strconv.FormatFloat(goal, 'f', -1, 64),
+		"dl": strconv.FormatUint(deadline, 10),
+	}, txID)
+}
+
+// EmitContributionEvent emits an event for a new contribution.
+func EmitContributionEvent(escrowID uint64, address string, amount float64, txID string) {
+	emitEvent("co", map[string]string{
+		"id": strconv.FormatUint(escrowID, 10),
+		"a":  address,
+		"am": strconv.FormatFloat(amount, 'f', -1, 64),
+	}, txID)
+}
+
+// EmitEscrowFundedEvent emits an event once an escrow holds its full amount.
+func EmitEscrowFundedEvent(escrowID uint64, amount float64, txID string) {
+	emitEvent("fu", map[string]string{
+		"id": strconv.FormatUint(escrowID, 10),
+		"am": strconv.FormatFloat(amount, 'f', -1, 64),
+	}, txID)
+}
+
+// EmitWithdrawalEvent emits an event for a withdrawn contribution.
+func EmitWithdrawalEvent(escrowID uint64, address string, amount float64, txID string) {
+	emitEvent("wd", map[string]string{
+		"id": strconv.FormatUint(escrowID, 10),
+		"a":  address,
+		"am": strconv.FormatFloat(amount, 'f', -1, 64),
+	}, txID)
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"okinoko_escrow/sdk"
+	"strconv"
+	"strings"
+)
+
+// =====================
+// Escrow Dependencies
+// =====================
+
+// EscrowDependency describes the escrow that must close with a required outcome first.
+type EscrowDependency struct {
+	ID      uint64 `json:"id"`
+	Outcome string `json:"o"`
+}
+
+// parseDependencyOption parses the dependency option value (escrowId:r|f).
+func parseDependencyOption(value string) *EscrowDependency {
+	idStr, outcome, found := strings.Cut(value, ":")
+	if !found || (outcome != "r" && outcome != "f") {
+		sdk.Abort("invalid dependency: expected escrowId:r|f")
+	}
+	id, err := strconv.ParseUint(idStr, 10, 64)
+	if err != nil {
+		sdk.Abort("invalid dependency: escrow ID must be a number")
+	}
+	return &EscrowDependency{ID: id, Outcome: outcome}
+}
+
+// ResolveDependency refunds an open escrow whose dependency failed; callable by anyone.
+//
+//go:wasmexport e_resolve
+func ResolveDependency(id *string) *string {
+	escrowID := StringToUInt64(id)
+	requireOpenEscrow(escrowID)
+	dep := loadEscrowDependency(escrowID)
+	if dep == nil {
+		sdk.Abort("escrow has no dependency")
+	}
+	if !dependencyFailed(*dep) {
+		sdk.Abort("dependency not failed")
+	}
+	txID := sdk.GetEnvKey("tx.id")
+	finalizeEscrow(escrowID, DecisionRefund, *txID)
+	return nil
+}
+
+// validateDependency checks that the dependency exists and can still be met.
+// A new escrow can only depend on an older one, so dependencies cannot form cycles.
+func validateDependency(dep EscrowDependency) {
+	if dep.ID >= newEscrowID() {
+		sdk.Abort("dependency escrow not found")
+	}
+	if dependencyFailed(dep) {
+		sdk.Abort("dependency can no longer be met")
+	}
+}
+
+// dependencyFailed reports whether the dependency closed with another outcome than required
+// (including void and settled closes) or expired unfunded.
+func dependencyFailed(dep EscrowDependency) bool {
+	if closed, outcome := loadEscrowOutcome(dep.ID, loadDecisions(dep.ID)); closed {
+		return friendlyOutcome(outcome) != dep.Outcome
+	}
+	return fundingExpired(dep.ID)
+}
+
+// requireDependencyMet aborts unless the escrow's dependency closed with the required outcome.
+// Only releases wait for the dependency; refunds are always possible.
+func requireDependencyMet(escrowID uint64) {
+	dep := loadEscrowDependency(escrowID)
+	if dep == nil {
+		return
+	}
+	closed, outcome := loadEscrowOutcome(dep.ID, loadDecisions(dep.ID))
+	if !closed || friendlyOutcome(outcome) != dep.Outcome {
+		sdk.Abort("dependency not met")
+	}
+}
+
+// formatDependency encodes a dependency as escrowId:outcome.
+func formatDependency(dep EscrowDependency) string {
+	return strconv.FormatUint(dep.ID, 10) + ":" + dep.Outcome
+}
+
+// saveEscrowDependency stores the dependency of an escrow.
+func saveEscrowDependency(escrowID uint64, dep EscrowDependency) {
+	key := strconv.FormatUint(escrowID, 10) + "|dp"
+	sdk.StateSetObject(key, formatDependency(dep))
+}
+
+// loadEscrowDependency retrieves the dependency of an escrow; nil if it has none.
+func loadEscrowDependency(escrowID uint64) *EscrowDependency {
+	key := strconv.FormatUint(escrowID, 10) + "|dp"
+	ptr := sdk.StateGetObject(key)
+	if ptr == nil || *ptr == "" {
+		return nil
+	}
+	idStr, outcome, found := strings.Cut(*ptr, ":")
+	if !found {
+		sdk.Abort("invalid dependency data")
+	}
+	return &EscrowDependency{ID: StringToUInt64(&idStr), Outcome: outcome}
+}
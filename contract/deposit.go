@@ -0,0 +1,351 @@
+package main
+
+import (
+	"okinoko_escrow/sdk"
+	"strconv"
+	"strings"
+)
+
+// =====================
+// Deposits
+// =====================
+
+const (
+	// ClaimPending is a deduction line awaiting the payer's response.
+	ClaimPending = "p"
+	// ClaimAccepted is a deduction line accepted by the payer.
+	ClaimAccepted = "a"
+	// ClaimContested is a deduction line contested by the payer and awaiting a ruling.
+	ClaimContested = "c"
+	// ClaimRuled is a deduction line ruled by the arbitrator.
+	ClaimRuled = "r"
+)
+
+// EscrowClaim describes an itemized deduction line of a deposit escrow.
+type EscrowClaim struct {
+	Amount   float64 `json:"am"`
+	Reason   string  `json:"rc"`
+	Evidence string  `json:"ev"`
+	State    string  `json:"st"`
+	Awarded  float64 `json:"aw"`
+}
+
+// depositClaim holds a stored deduction line (amounts in milli).
+type depositClaim struct {
+	Amount   uint64
+	Reason   string
+	Evidence string
+	State    string
+	Awarded  uint64
+}
+
+// parseDepositOption parses the deposit option value (claim deadline as block height).
+func parseDepositOption(value string) uint64 {
+	deadline, err := strconv.ParseUint(value, 10, 64)
+	if err != nil || deadline <= currentBlockHeight() {
+		sdk.Abort("invalid deposit: claim deadline must be a future block height")
+	}
+	return deadline
+}
+
+// ClaimDeduction lets the holder claim a deduction line (EscrowID|Amount|ReasonCode|EvidenceHash).
+// The amount is given in milli units; all lines together cannot exceed the deposit.
+//
+//go:wasmexport e_claim
+func ClaimDeduction(payload *string) *string {
+	if payload == nil || *payload == "" {
+		sdk.Abort("input CSV is nil or empty")
+	}
+	parts := strings.Split(*payload, "|")
+	if len(parts) != 4 {
+		sdk.Abort("invalid CSV format: expected EscrowID|Amount|ReasonCode|EvidenceHash")
+	}
+	escrowID := StringToUInt64(&parts[0])
+	amount, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil || amount == 0 {
+		sdk.Abort("invalid amount: must be a milli amount >0")
+	}
+	if parts[2] == "" || parts[3] == "" {
+		sdk.Abort("reason code and evidence hash are mandatory")
+	}
+	requireOpenDeposit(escrowID)
+	if currentBlockHeight() > loadDeadline(escrowID) {
+		sdk.Abort("claim period over")
+	}
+
+	sender := actingAddress()
+	if *sender != loadRoles(escrowID)[1] {
+		sdk.Abort("only the holder can claim deductions")
+	}
+
+	claims := loadClaims(escrowID)
+	total := amount
+	for _, c := range claims {
+		total += c.Amount
+	}
+	deposit, _ := loadReward(escrowID)
+	if total > deposit {
+		sdk.Abort("deductions exceed the deposit")
+	}
+
+	line := uint64(len(claims))
+	saveClaim(escrowID, line, depositClaim{Amount: amount, Reason: parts[2], Evidence: parts[3], State: ClaimPending})
+	saveClaimCount(escrowID, line+1)
+
+	txID := sdk.GetEnvKey("tx.id")
+	EmitClaimEvent(escrowID, line, float64(amount)/1000, parts[2], parts[3], *txID)
+	result := strconv.FormatUint(line, 10)
+	return &result
+}
+
+// RespondClaim lets the payer accept (a) or contest (c) a deduction line (EscrowID|Line|a/c).
+//
+//go:wasmexport e_respond
+func RespondClaim(payload *string) *string {
+	escrowID, line, answer := csvToClaimAction(payload, "EscrowID|Line|a/c")
+	if answer != ClaimAccepted && answer != ClaimContested {
+		sdk.Abort("invalid response: must be a/c")
+	}
+	requireOpenDeposit(escrowID)
+
+	sender := actingAddress()
+	if *sender != loadRoles(escrowID)[0] {
+		sdk.Abort("only the payer can respond to deductions")
+	}
+	claim := loadClaim(escrowID, line)
+	if claim.State != ClaimPending {
+		sdk.Abort("deduction already answered")
+	}
+	claim.State = answer
+	saveClaim(escrowID, line, *claim)
+
+	txID := sdk.GetEnvKey("tx.id")
+	EmitClaimResponseEvent(escrowID, line, answer, *txID)
+	return nil
+}
+
+// RuleClaim lets the arbitrator award a contested deduction line (EscrowID|Line|AwardedAmount).
+// Lines left unanswered after the claim period count as contested.
+//
+//go:wasmexport e_rule
+func RuleClaim(payload *string) *string {
+	escrowID, line, awardStr := csvToClaimAction(payload, "EscrowID|Line|AwardedAmount")
+	requireOpenDeposit(escrowID)
+
+	sender := actingAddress()
+	if *sender != loadRoles(escrowID)[2] {
+		sdk.Abort("only the arbitrator can rule on deductions")
+	}
+	claim := loadClaim(escrowID, line)
+	if !claimContested(escrowID, claim) {
+		sdk.Abort("deduction not contested")
+	}
+	award, err := strconv.ParseUint(awardStr, 10, 64)
+	if err != nil || award > claim.Amount {
+		sdk.Abort("invalid award: must be a milli amount up to the claimed amount")
+	}
+	claim.State = ClaimRuled
+	claim.Awarded = award
+	saveClaim(escrowID, line, *claim)
+
+	txID := sdk.GetEnvKey("tx.id")
+	EmitClaimRulingEvent(escrowID, line, float64(award)/1000, *txID)
+	return nil
+}
+
+// PayoutDeposit closes a deposit escrow once all deduction lines are resolved.
+// The holder may pay out anytime; anyone else only after the claim period.
+// The holder receives accepted and awarded deductions, the rest returns to the payer.
+//
+//go:wasmexport e_payout
+func PayoutDeposit(id *string) *string {
+	escrowID := StringToUInt64(id)
+	requireOpenDeposit(escrowID)
+
+	sender := actingAddress()
+	roles := loadRoles(escrowID)
+	if *sender != roles[1] && currentBlockHeight() <= loadDeadline(escrowID) {
+		sdk.Abort("claim period not over")
+	}
+
+	var deducted uint64
+	for _, c := range loadClaims(escrowID) {
+		switch c.State {
+		case ClaimAccepted:
+			deducted += c.Amount
+		case ClaimRuled:
+			deducted += c.Awarded
+		default:
+			sdk.Abort("unresolved deductions")
+		}
+	}
+
+	am, as := loadReward(escrowID)
+	if deducted > am {
+		sdk.Abort("deductions exceed the deposit held")
+	}
+	details := map[string]string{
+		"tf": strconv.FormatFloat(float64(am-deducted)/1000, 'f', -1, 64),
+		"tt": strconv.FormatFloat(float64(deducted)/1000, 'f', -1, 64),
+	}
+	if deducted > 0 {
+		if childID := releaseToReceivers(escrowID, deducted, as, roles[1]); childID > 0 {
+			details["ch"] = strconv.FormatUint(childID, 10)
+		}
+	}
+	if am > deducted {
+		refundToSender(escrowID, am-deducted, as, roles[0])
+	}
+
+	for k, v := range settleBond(escrowID, OutcomeSettled, roles, as) {
+		details[k] = v
+	}
+	txID := sdk.GetEnvKey("tx.id")
+	closeEscrow(escrowID, OutcomeSettled, am, details, *txID)
+	return nil
+}
+
+// =====================
+// Deposit Helpers
+// =====================
+
+// csvToClaimAction parses EscrowID|Line|Value payloads of deduction actions.
+func csvToClaimAction(payload *string, format string) (uint64, uint64, string) {
+	if payload == nil || *payload == "" {
+		sdk.Abort("input CSV is nil or empty")
+	}
+	parts := strings.Split(*payload, "|")
+	if len(parts) != 3 {
+		sdk.Abort("invalid CSV format: expected " + format)
+	}
+	return StringToUInt64(&parts[0]), StringToUInt64(&parts[1]), parts[2]
+}
+
+// requireOpenDeposit aborts unless the escrow is an active, open deposit.
+func requireOpenDeposit(escrowID uint64) {
+	if loadKind(escrowID) != KindDeposit {
+		sdk.Abort("escrow is not a deposit")
+	}
+	if loadStatus(escrowID) != StatusActive {
+		sdk.Abort("escrow not active")
+	}
+	if closed, _ := loadEscrowOutcome(escrowID, loadDecisions(escrowID)); closed {
+		sdk.Abort("escrow already closed")
+	}
+}
+
+// claimContested reports whether a line awaits a ruling; unanswered lines do after the claim period.
+func claimContested(escrowID uint64, claim *depositClaim) bool {
+	switch claim.State {
+	case ClaimContested:
+		return true
+	case ClaimPending:
+		return currentBlockHeight() > loadDeadline(escrowID)
+	}
+	return false
+}
+
+// saveClaimCount stores the number of deduction lines of a deposit.
+func saveClaimCount(escrowID uint64, n uint64) {
+	key := strconv.FormatUint(escrowID, 10) + "|dc"
+	sdk.StateSetObject(key, strconv.FormatUint(n, 10))
+}
+
+// loadClaimCount retrieves the number of deduction lines of a deposit.
+func loadClaimCount(escrowID uint64) uint64 {
+	key := strconv.FormatUint(escrowID, 10) + "|dc"
+	ptr := sdk.StateGetObject(key)
+	if ptr == nil || *ptr == "" {
+		return 0
+	}
+	return StringToUInt64(ptr)
+}
+
+// saveClaim stores amount|state|awarded|reason|evidence of a deduction line.
+func saveClaim(escrowID uint64, line uint64, c depositClaim) {
+	key := strconv.FormatUint(escrowID, 10) + "|dc|" + strconv.FormatUint(line, 10)
+	buf := make([]byte, 0, 48+len(c.Reason)+len(c.Evidence))
+	buf = strconv.AppendUint(buf, c.Amount, 10)
+	buf = append(buf, '|')
+	buf = append(buf, c.State...)
+	buf = append(buf, '|')
+	buf = strconv.AppendUint(buf, c.Awarded, 10)
+	buf = append(buf, '|')
+	buf = append(buf, c.Reason...)
+	buf = append(buf, '|')
+	buf = append(buf, c.Evidence...)
+	sdk.StateSetObject(key, string(buf))
+}
+
+// loadClaim retrieves a deduction line of a deposit.
+func loadClaim(escrowID uint64, line uint64) *depositClaim {
+	key := strconv.FormatUint(escrowID, 10) + "|dc|" + strconv.FormatUint(line, 10)
+	ptr := sdk.StateGetObject(key)
+	if ptr == nil || *ptr == "" {
+		sdk.Abort("deduction not found")
+	}
+	parts := strings.Split(*ptr, "|")
+	if len(parts) != 5 {
+		sdk.Abort("invalid deduction data")
+	}
+	return &depositClaim{
+		Amount:   StringToUInt64(&parts[0]),
+		State:    parts[1],
+		Awarded:  StringToUInt64(&parts[2]),
+		Reason:   parts[3],
+		Evidence: parts[4],
+	}
+}
+
+// loadClaims retrieves all deduction lines of a deposit.
+func loadClaims(escrowID uint64) []depositClaim {
+	n := loadClaimCount(escrowID)
+	claims := make([]depositClaim, n)
+	for i := uint64(0); i < n; i++ {
+		claims[i] = *loadClaim(escrowID, i)
+	}
+	return claims
+}
+
+// fillDeposit adds the claim deadline and the deduction lines to an escrow view.
+func fillDeposit(escrow *Escrow) {
+	escrow.Deadline = loadDeadline(escrow.ID)
+	for _, c := range loadClaims(escrow.ID) {
+		escrow.Claims = append(escrow.Claims, EscrowClaim{
+			Amount:   float64(c.Amount) / 1000,
+			Reason:   c.Reason,
+			Evidence: c.Evidence,
+			State:    c.State,
+			Awarded:  float64(c.Awarded) / 1000,
+		})
+	}
+}
+
+// EmitClaimEvent emits an event for a claimed deduction line.
+func EmitClaimEvent(escrowID uint64, line uint64, amount float64, reason string, evidence string, txID string) {
+	emitEvent("dc", map[string]string{
+		"id": strconv.FormatUint(escrowID, 10),
+		"l":  strconv.FormatUint(line, 10),
+		"am": strconv.FormatFloat(amount, 'f', -1, 64),
+		"rc": reason,
+		"ev": evidence,
+	}, txID)
+}
+
+// EmitClaimResponseEvent emits an event for the payer's response to a deduction line.
+func EmitClaimResponseEvent(escrowID uint64, line uint64, state string, txID string) {
+	emitEvent("dr", map[string]string{
+		"id": strconv.FormatUint(escrowID, 10),
+		"l":  strconv.FormatUint(line, 10),
+		"st": state,
+	}, txID)
+}
+
+// EmitClaimRulingEvent emits an event for the arbitrator's ruling on a deduction line.
+func EmitClaimRulingEvent(escrowID uint64, line uint64, awarded float64, txID string) {
+	emitEvent("du", map[string]string{
+		"id": strconv.FormatUint(escrowID, 10),
+		"l":  strconv.FormatUint(line, 10),
+		"aw": strconv.FormatFloat(awarded, 'f', -1, 64),
+	}, txID)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"okinoko_escrow/sdk"
+	"strconv"
+	"strings"
+)
+
+// =====================
+// Forwarded Releases
+// =====================
+
+// EscrowForward describes a pending instruction to forward part of a release into a child escrow.
+type EscrowForward struct {
+	Share      uint64 `json:"sh"`
+	Name       string `json:"n"`
+	To         string `json:"t"`
+	Arbitrator string `json:"arb"`
+}
+
+// RegisterForward lets the receiver forward part of a future release into a new child escrow
+// (EscrowID|ShareBps|Name|ChildReceiver|ChildArbitrator). A share of 0 removes the instruction.
+//
+//go:wasmexport e_forward
+func RegisterForward(payload *string) *string {
+	if payload == nil || *payload == "" {
+		sdk.Abort("input CSV is nil or empty")
+	}
+	parts := strings.Split(*payload, "|")
+	if len(parts) != 2 && len(parts) != 5 {
+		sdk.Abort("invalid CSV format: expected EscrowID|ShareBps|Name|ChildReceiver|ChildArbitrator")
+	}
+	escrowID := StringToUInt64(&parts[0])
+	share, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil || share > maxBasisPoints {
+		sdk.Abort("invalid share: must be 0-10000 basis points")
+	}
+	if closed, _ := loadEscrowOutcome(escrowID, loadDecisions(escrowID)); closed {
+		sdk.Abort("escrow already closed")
+	}
+	if loadKind(escrowID) == KindWager {
+		sdk.Abort("wagers cannot be forwarded")
+	}
+	if len(loadEscrowReceivers(escrowID)) > 0 {
+		sdk.Abort("escrows with multiple receivers cannot be forwarded")
+	}
+
+	sender := actingAddress()
+	if *sender != loadRoles(escrowID)[1] {
+		sdk.Abort("only the receiver can forward a release")
+	}
+
+	txID := sdk.GetEnvKey("tx.id")
+	if share == 0 {
+		sdk.StateDeleteObject(strconv.FormatUint(escrowID, 10) + "|fw")
+		EmitForwardEvent(escrowID, EscrowForward{}, *txID)
+		return nil
+	}
+	if len(parts) != 5 {
+		sdk.Abort("invalid CSV format: expected EscrowID|ShareBps|Name|ChildReceiver|ChildArbitrator")
+	}
+	fw := EscrowForward{Share: share, Name: parts[2], To: parts[3], Arbitrator: parts[4]}
+	child := CreateEscrowArgs{Name: fw.Name, To: fw.To, Arbitrator: fw.Arbitrator}
+	child.Validate(*sender)
+	if fw.To == *sender {
+		sdk.Abort("receiver must differ from sender")
+	}
+	saveEscrowForward(escrowID, fw)
+
+	EmitForwardEvent(escrowID, fw, *txID)
+	return nil
+}
+
+// forwardRelease moves the forwarded share of a release into a new child escrow funded by the receiver.
+// Every release to the receiver (final, partial, settled, units, deductions) funds its own child escrow.
+// It returns the forwarded amount (milli) and the child escrow ID.
+func forwardRelease(escrowID uint64, amount uint64, asset string, receiver string) (uint64, uint64) {
+	fw := loadEscrowForward(escrowID)
+	if fw == nil {
+		return 0, 0
+	}
+	forwarded := mulDiv(amount, fw.Share, maxBasisPoints)
+	if forwarded == 0 {
+		return 0, 0
+	}
+
+	childID := newEscrowID()
+	initEscrow(childID, fw.Name, receiver, fw.To, fw.Arbitrator, forwarded, asset)
+	sdk.StateSetObject(strconv.FormatUint(childID, 10)+"|pa", strconv.FormatUint(escrowID, 10))
+	appendEscrowChild(escrowID, childID)
+
+	txID := sdk.GetEnvKey("tx.id")
+	EmitEscrowCreatedEvent(childID, receiver, fw.To, fw.Arbitrator, float64(forwarded)/1000, asset, map[string]string{
+		"pa": strconv.FormatUint(escrowID, 10),
+	}, *txID)
+	return forwarded, childID
+}
+
+// appendEscrowChild adds a child escrow ID to the comma-separated children (|ch) of a parent.
+func appendEscrowChild(escrowID uint64, childID uint64) {
+	key := strconv.FormatUint(escrowID, 10) + "|ch"
+	entry := strconv.FormatUint(childID, 10)
+	if ptr := sdk.StateGetObject(key); ptr != nil && *ptr != "" {
+		entry = *ptr + "," + entry
+	}
+	sdk.StateSetObject(key, entry)
+}
+
+// loadEscrowChildren retrieves the child escrow IDs funded by forwarded releases.
+func loadEscrowChildren(escrowID uint64) []uint64 {
+	ptr := sdk.StateGetObject(strconv.FormatUint(escrowID, 10) + "|ch")
+	if ptr == nil || *ptr == "" {
+		return nil
+	}
+	entries := strings.Split(*ptr, ",")
+	children := make([]uint64, len(entries))
+	for i := range entries {
+		children[i] = StringToUInt64(&entries[i])
+	}
+	return children
+}
+
+// saveEscrowForward stores share|name|to|arb of a forward instruction.
+func saveEscrowForward(escrowID uint64, fw EscrowForward) {
+	key := strconv.FormatUint(escrowID, 10) + "|fw"
+	sdk.StateSetObject(key, strconv.FormatUint(fw.Share, 10)+"|"+fw.Name+"|"+fw.To+"|"+fw.Arbitrator)
+}
+
+// loadEscrowForward retrieves the forward instruction of an escrow; nil if it has none.
+func loadEscrowForward(escrowID uint64) *EscrowForward {
+	key := strconv.FormatUint(escrowID, 10) + "|fw"
+	ptr := sdk.StateGetObject(key)
+	if ptr == nil || *ptr == "" {
+		return nil
+	}
+	parts := strings.Split(*ptr, "|")
+	if len(parts) != 4 {
+		sdk.Abort("invalid forward data")
+	}
+	return &EscrowForward{Share: StringToUInt64(&parts[0]), Name: parts[1], To: parts[2], Arbitrator: parts[3]}
+}
+
+// loadEscrowParent returns the parent escrow ID of a child escrow funded by a forwarded release, if any.
+func loadEscrowParent(escrowID uint64) *uint64 {
+	ptr := sdk.StateGetObject(strconv.FormatUint(escrowID, 10) + "|pa")
+	if ptr == nil || *ptr == "" {
+		return nil
+	}
+	id := StringToUInt64(ptr)
+	return &id
+}
+
+// EmitForwardEvent emits an event for a registered or removed forward instruction.
+func EmitForwardEvent(escrowID uint64, fw EscrowForward, txID string) {
+	emitEvent("fw", map[string]string{
+		"id":  strconv.FormatUint(escrowID, 10),
+		"sh":  strconv.FormatUint(fw.Share, 10),
+		"t":   fw.To,
+		"arb": fw.Arbitrator,
+	}, txID)
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"okinoko_escrow/sdk"
+	"strconv"
+	"strings"
+)
+
+// =====================
+// Dead-Man Switch
+// =====================
+
+// EscrowHeartbeat describes the heartbeat interval and last heartbeat of a dead-man-switch escrow.
+type EscrowHeartbeat struct {
+	Interval uint64 `json:"iv"`
+	Last     uint64 `json:"lh"`
+}
+
+// parseHeartbeatOption parses the heartbeat option value (interval in blocks).
+func parseHeartbeatOption(value string) uint64 {
+	interval, err := strconv.ParseUint(value, 10, 64)
+	if err != nil || interval == 0 {
+		sdk.Abort("invalid heartbeat: interval must be a number of blocks >0")
+	}
+	return interval
+}
+
+// Heartbeat records a sign of life from the sender of a dead-man-switch escrow.
+//
+//go:wasmexport e_heartbeat
+func Heartbeat(id *string) *string {
+	escrowID := StringToUInt64(id)
+	hb := requireOpenHeartbeat(escrowID)
+
+	sender := actingAddress()
+	roles := loadRoles(escrowID)
+	if *sender != roles[0] {
+		sdk.Abort("only the sender can send heartbeats")
+	}
+
+	hb.Last = currentBlockHeight()
+	saveEscrowHeartbeat(escrowID, *hb)
+
+	txID := sdk.GetEnvKey("tx.id")
+	EmitHeartbeatEvent(escrowID, hb.Last, *txID)
+	return nil
+}
+
+// TriggerRelease releases a dead-man-switch escrow to the receiver once the sender missed
+// a heartbeat; callable by anyone.
+//
+//go:wasmexport e_trigger
+func TriggerRelease(id *string) *string {
+	escrowID := StringToUInt64(id)
+	hb := requireOpenHeartbeat(escrowID)
+	if heartbeatCurrent(hb) {
+		sdk.Abort("heartbeat still current")
+	}
+
+	sender := actingAddress()
+	txID := sdk.GetEnvKey("tx.id")
+	EmitTriggerEvent(escrowID, *sender, hb.Last, *txID)
+	finalizeEscrow(escrowID, DecisionRelease, *txID)
+	return nil
+}
+
+// requireOpenHeartbeat aborts unless the escrow is an open dead-man switch and returns its heartbeat.
+func requireOpenHeartbeat(escrowID uint64) *EscrowHeartbeat {
+	if loadKind(escrowID) != KindHeartbeat {
+		sdk.Abort("escrow is not a dead-man switch")
+	}
+	if closed, _ := loadEscrowOutcome(escrowID, loadDecisions(escrowID)); closed {
+		sdk.Abort("escrow already closed")
+	}
+	return loadEscrowHeartbeat(escrowID)
+}
+
+// heartbeatCurrent reports whether the last heartbeat is still within the interval.
+func heartbeatCurrent(hb *EscrowHeartbeat) bool {
+	return currentBlockHeight() <= hb.Last+hb.Interval
+}
+
+// saveEscrowHeartbeat stores interval|lastHeartbeat of a dead-man-switch escrow.
+func saveEscrowHeartbeat(escrowID uint64, hb EscrowHeartbeat) {
+	key := strconv.FormatUint(escrowID, 10) + "|hb"
+	buf := make([]byte, 0, 40)
+	buf = strconv.AppendUint(buf, hb.Interval, 10)
+	buf = append(buf, '|')
+	buf = strconv.AppendUint(buf, hb.Last, 10)
+	sdk.StateSetObject(key, string(buf))
+}
+
+// loadEscrowHeartbeat retrieves the heartbeat of a dead-man-switch escrow; nil if it has none.
+func loadEscrowHeartbeat(escrowID uint64) *EscrowHeartbeat {
+	key := strconv.FormatUint(escrowID, 10) + "|hb"
+	ptr := sdk.StateGetObject(key)
+	if ptr == nil || *ptr == "" {
+		return nil
+	}
+	interval, last, found := strings.Cut(*ptr, "|")
+	if !found {
+		sdk.Abort("invalid heartbeat data")
+	}
+	return &EscrowHeartbeat{
+		Interval: StringToUInt64(&interval),
+		Last:     StringToUInt64(&last),
+	}
+}
+
+// EmitHeartbeatEvent emits an event for a sender heartbeat.
+func EmitHeartbeatEvent(escrowID uint64, height uint64, txID string) {
+	emitEvent("hb", map[string]string{
+		"id": strconv.FormatUint(escrowID, 10),
+		"h":  strconv.FormatUint(height, 10),
+	}, txID)
+}
+
+// EmitTriggerEvent emits an event for a release triggered by a missed heartbeat.
+func EmitTriggerEvent(escrowID uint64, address string, lastHeartbeat uint64, txID string) {
+	emitEvent("tr", map[string]string{
+		"id": strconv.FormatUint(escrowID, 10),
+		"a":  address,
+		"lh": strconv.FormatUint(lastHeartbeat, 10),
+	}, txID)
+}
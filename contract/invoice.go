@@ -0,0 +1,166 @@
+package main
+
+import (
+	"okinoko_escrow/sdk"
+	"strconv"
+	"strings"
+)
+
+// =====================
+// Invoices
+// =====================
+
+// CreateInvoiceArgs are arguments for a receiver-issued, unfunded escrow.
+type CreateInvoiceArgs struct {
+	Name       string
+	Payer      string
+	Arbitrator string
+	Amount     uint64
+	Asset      string
+	Expiry     uint64
+}
+
+// CsvToCreateInvoiceArgs parses a pipe-delimited string into CreateInvoiceArgs
+// (Name|Payer|Arbitrator|Amount|Asset|Expiry). Amount is given in milli units, Expiry as block height.
+func CsvToCreateInvoiceArgs(csv *string) CreateInvoiceArgs {
+	if csv == nil || *csv == "" {
+		sdk.Abort("input CSV is nil or empty")
+	}
+
+	parts := strings.Split(*csv, "|")
+	if len(parts) != 6 {
+		sdk.Abort("invalid CSV format: expected 6 fields (Name|Payer|Arbitrator|Amount|Asset|Expiry)")
+	}
+
+	amount, err := strconv.ParseUint(parts[3], 10, 64)
+	if err != nil {
+		sdk.Abort("invalid amount: must be a milli amount")
+	}
+	expiry, err := strconv.ParseUint(parts[5], 10, 64)
+	if err != nil {
+		sdk.Abort("invalid expiry: must be a block height")
+	}
+
+	return CreateInvoiceArgs{
+		Name:       parts[0],
+		Payer:      parts[1],
+		Arbitrator: parts[2],
+		Amount:     amount,
+		Asset:      parts[4],
+		Expiry:     expiry,
+	}
+}
+
+// Validate checks the semantic correctness of CreateInvoiceArgs issued by the receiver.
+func (c *CreateInvoiceArgs) Validate(receiverAddress string) {
+	base := CreateEscrowArgs{Name: c.Name, To: receiverAddress, Arbitrator: c.Arbitrator}
+	if c.Payer == "" {
+		sdk.Abort("payer is mandatory")
+	}
+	base.Validate(c.Payer)
+	if c.Payer == receiverAddress {
+		sdk.Abort("payer must differ from receiver")
+	}
+	if c.Amount == 0 {
+		sdk.Abort("amount >0 needed")
+	}
+	if !isValidAsset(c.Asset) {
+		sdk.Abort("asset not supported")
+	}
+	if c.Expiry <= currentBlockHeight() {
+		sdk.Abort("expiry must be in the future")
+	}
+}
+
+// CreateInvoice creates an unfunded escrow issued by the receiver and naming the payer.
+//
+//go:wasmexport e_create_inv
+func CreateInvoice(payload *string) *string {
+	input := CsvToCreateInvoiceArgs(payload)
+	receiver := actingAddress()
+
+	input.Validate(*receiver)
+
+	// The reward holds the invoiced amount; funds arrive with the payment.
+	escrowID := newEscrowID()
+	initEscrow(escrowID, input.Name, input.Payer, *receiver, input.Arbitrator, input.Amount, input.Asset)
+	saveEscrowKind(escrowID, KindInvoice)
+	saveEscrowStatus(escrowID, StatusFunding)
+	saveEscrowDeadline(escrowID, input.Expiry)
+
+	txID := sdk.GetEnvKey("tx.id")
+	EmitInvoiceCreatedEvent(
+		escrowID,
+		input.Payer,
+		*receiver,
+		input.Arbitrator,
+		float64(input.Amount)/1000,
+		input.Asset,
+		input.Expiry,
+		*txID)
+
+	result := strconv.FormatUint(escrowID, 10)
+	return &result
+}
+
+// PayInvoice funds an open invoice from the named payer's transfer.allow intent.
+//
+//go:wasmexport e_pay_invoice
+func PayInvoice(id *string) *string {
+	escrowID := StringToUInt64(id)
+	if loadKind(escrowID) != KindInvoice {
+		sdk.Abort("escrow is not an invoice")
+	}
+	if loadStatus(escrowID) != StatusFunding {
+		sdk.Abort("invoice already paid")
+	}
+	if fundingExpired(escrowID) {
+		sdk.Abort("invoice expired")
+	}
+
+	sender := actingAddress()
+	roles := loadRoles(escrowID)
+	if *sender != roles[0] {
+		sdk.Abort("only the named payer can pay the invoice")
+	}
+
+	amount, asset := loadReward(escrowID)
+	ta := GetFirstTransferAllow(sdk.GetEnv().Intents)
+	if ta == nil {
+		sdk.Abort("intent needed")
+	}
+	if ta.Token.String() != asset {
+		sdk.Abort("intent asset does not match invoice asset")
+	}
+	if ta.LimitMilli < amount {
+		sdk.Abort("intent below invoice amount")
+	}
+
+	drawFunds(amount, ta.Token.String())
+	saveEscrowStatus(escrowID, StatusActive)
+
+	txID := sdk.GetEnvKey("tx.id")
+	EmitEscrowFundedEvent(escrowID, float64(amount)/1000, *txID)
+	return nil
+}
+
+// fillInvoice adds the expiry to an escrow view and marks unpaid, expired invoices as closed.
+func fillInvoice(escrow *Escrow) {
+	escrow.Deadline = loadDeadline(escrow.ID)
+	if fundingExpired(escrow.ID) {
+		escrow.Closed = true
+		escrow.Status = StatusExpired
+	}
+}
+
+// =====================
+// Invoice Events
+// =====================
+
+// EmitInvoiceCreatedEvent emits an event for a newly issued invoice.
+func EmitInvoiceCreatedEvent(escrowID uint64, payerAddress string, receiverAddress string, arbAddress string, amount float64, asset string, expiry uint64, txID string) {
+	EmitEscrowCreatedEvent(escrowID, payerAddress, receiverAddress, arbAddress, amount, asset, map[string]string{
+		"k":  KindInvoice,
+		"dl": strconv.FormatUint(expiry, 10),
+	}, txID)
+}
@@ -3,6 +3,7 @@ package main
 import (
 	"encoding/json"
 	"fmt"
+	"math/bits"
 	"okinoko_escrow/sdk"
 	"strconv"
 	"strings"
@@ -20,6 +21,34 @@ const (
 	DecisionRefund uint8 = 1
 	// DecisionRelease indicates a release decision.
 	DecisionRelease uint8 = 2
+	// OutcomeVoid indicates a closed escrow refunded to all stakers (wager tie or void).
+	OutcomeVoid uint8 = 3
+	// OutcomeSettled indicates a closed escrow split by an accepted settlement offer.
+	OutcomeSettled uint8 = 4
+
+	// KindStandard is a regular escrow funded by its creator.
+	KindStandard = "s"
+	// KindCrowdfund is an escrow funded by many backers towards a goal.
+	KindCrowdfund = "c"
+	// KindInvoice is an escrow issued by the receiver and funded later by the payer.
+	KindInvoice = "i"
+	// KindWager is an escrow staked by two parties where the arbitrator or an oracle picks the winner.
+	KindWager = "w"
+	// KindHeartbeat is a dead-man-switch escrow released when the sender stops sending heartbeats.
+	KindHeartbeat = "h"
+	// KindCofunded is an escrow funded by several senders voting by funded weight.
+	KindCofunded = "m"
+	// KindDeposit is a deposit returned to the payer minus itemized deductions claimed by the holder.
+	KindDeposit = "d"
+	// KindMetered is an escrow released per confirmed unit at a fixed unit price.
+	KindMetered = "q"
+
+	// StatusActive marks a funded escrow that accepts decisions.
+	StatusActive = "a"
+	// StatusFunding marks an escrow that still waits for its funds.
+	StatusFunding = "u"
+	// StatusExpired is reported for escrows whose funding deadline passed; it is never stored.
+	StatusExpired = "x"
 )
 
 // =====================
@@ -34,15 +63,46 @@ type EscrowAccount struct {
 
 // Escrow describes an escrow instance and its state.
 type Escrow struct {
-	ID         uint64        `json:"id"`
-	Name       string        `json:"n"`
-	From       EscrowAccount `json:"f"`
-	To         EscrowAccount `json:"t"`
-	Arbitrator EscrowAccount `json:"arb"`
-	Amount     float64       `json:"am"`
-	Asset      string        `json:"as"`
-	Closed     bool          `json:"cl"`
-	Outcome    uint8         `json:"o"`
+	ID           uint64                  `json:"id"`
+	Name         string                  `json:"n"`
+	From         EscrowAccount           `json:"f"`
+	To           EscrowAccount           `json:"t"`
+	Arbitrator   EscrowAccount           `json:"arb"`
+	Amount       float64                 `json:"am"`
+	Asset        string                  `json:"as"`
+	Closed       bool                    `json:"cl"`
+	Outcome      uint8                   `json:"o"`
+	Kind         string                  `json:"k,omitempty"`
+	Status       string                  `json:"s,omitempty"`
+	Goal         float64                 `json:"g,omitempty"`
+	Deadline     uint64                  `json:"dl,omitempty"`
+	Backers      []EscrowBacker          `json:"b,omitempty"`
+	Subscription *uint64                 `json:"sub,omitempty"`
+	Bond         *EscrowBond             `json:"bo,omitempty"`
+	Wager        *EscrowWager            `json:"w,omitempty"`
+	Heartbeat    *EscrowHeartbeat        `json:"hb,omitempty"`
+	Receivers    []EscrowShare           `json:"rcv,omitempty"`
+	Released     float64                 `json:"rel,omitempty"`
+	Offers       []EscrowOffer           `json:"of,omitempty"`
+	Claims       []EscrowClaim           `json:"dd,omitempty"`
+	Units        *EscrowUnits            `json:"u,omitempty"`
+	Penalty      *EscrowPenalty          `json:"lt,omitempty"`
+	After        *EscrowDependency       `json:"af,omitempty"`
+	Forward      *EscrowForward          `json:"fw,omitempty"`
+	Assignment   *EscrowAssignment       `json:"asg,omitempty"`
+	Rotations    []EscrowRotation        `json:"rot,omitempty"`
+	Refund       string                  `json:"rf,omitempty"`
+	Payouts      map[string]EscrowPayout `json:"po,omitempty"`
+	Withdraw     bool                    `json:"wd,omitempty"`
+	Callback     *EscrowCallback         `json:"cb,omitempty"`
+	Parent       *uint64                 `json:"pa,omitempty"`
+	Children     []uint64                `json:"ch,omitempty"`
+}
+
+// EscrowBacker represents a funder of an escrow and their contribution.
+type EscrowBacker struct {
+	Address string  `json:"a"`
+	Amount  float64 `json:"am"`
 }
 
 // CreateEscrowArgs are arguments to create a new escrow.
@@ -50,6 +110,21 @@ type CreateEscrowArgs struct {
 	Name       string
 	To         string
 	Arbitrator string
+	Bond       uint64
+	BondShare  uint64
+	Heartbeat  uint64
+	Receivers  []EscrowShare
+	Cofund     bool
+	Deposit    uint64
+	Units      *unitTerms
+	Expiry     uint64
+	Penalty    *penaltyTerms
+	After      *EscrowDependency
+	Assign     uint64
+	From       string
+	Refund     string
+	Withdraw   bool
+	Callback   *EscrowCallback
 }
 
 // DecisionArgs are arguments to add a decision to an escrow.
@@ -63,21 +138,61 @@ type DecisionArgs struct {
 // =====================
 
 // CsvToCreateEscrowArgs parses a pipe-delimited string into CreateEscrowArgs (Name|To|Arbitrator).
+// Optional settings may follow as key=value fields (e.g. Name|To|Arbitrator|bond=5000:2500).
 func CsvToCreateEscrowArgs(csv *string) CreateEscrowArgs {
 	if csv == nil || *csv == "" {
 		sdk.Abort("input CSV is nil or empty")
 	}
 
 	parts := strings.Split(*csv, "|")
-	if len(parts) != 3 {
-		sdk.Abort("invalid CSV format: expected 3 fields (Name|To|Arbitrator)")
+	if len(parts) < 3 {
+		sdk.Abort("invalid CSV format: expected at least 3 fields (Name|To|Arbitrator)")
 	}
 
-	return CreateEscrowArgs{
+	args := CreateEscrowArgs{
 		Name:       parts[0],
 		To:         parts[1],
 		Arbitrator: parts[2],
 	}
+	for _, opt := range parts[3:] {
+		key, value, found := strings.Cut(opt, "=")
+		if !found {
+			sdk.Abort("invalid option format: expected key=value")
+		}
+		switch key {
+		case "bond":
+			args.Bond, args.BondShare = parseBondOption(value)
+		case "hb":
+			args.Heartbeat = parseHeartbeatOption(value)
+		case "rcv":
+			args.Receivers = parseReceiversOption(value)
+		case "cof":
+			args.Cofund = parseCofundOption(value)
+		case "dep":
+			args.Deposit = parseDepositOption(value)
+		case "unit":
+			terms, expiry := parseUnitsOption(value)
+			args.Units, args.Expiry = &terms, expiry
+		case "late":
+			terms := parsePenaltyOption(value)
+			args.Penalty = &terms
+		case "after":
+			args.After = parseDependencyOption(value)
+		case "asg":
+			args.Assign = parseAssignOption(value)
+		case "from":
+			args.From = value
+		case "rfd":
+			args.Refund = value
+		case "wd":
+			args.Withdraw = parseWithdrawOption(value)
+		case "cb":
+			args.Callback = parseCallbackOption(value)
+		default:
+			sdk.Abort("unknown option: " + key)
+		}
+	}
+	return args
 }
 
 // CsvToDecisionArgs parses a pipe-delimited string into DecisionArgs (EscrowID|Decision).
@@ -155,9 +270,10 @@ func CsvToReward(csv *string) (uint64, string) {
 //go:wasmexport e_create
 func CreateEscrow(payload *string) *string {
 	input := CsvToCreateEscrowArgs(payload)
-	creator := sdk.GetEnvKey("msg.sender")
+	creator := actingAddress()
 
 	input.Validate(*creator)
+	from, refund := resolveSponsorship(input, *creator)
 
 	escrowID := newEscrowID()
 	ta := GetFirstTransferAllow(sdk.GetEnv().Intents)
@@ -170,41 +286,152 @@ func CreateEscrow(payload *string) *string {
 	if !isValidAsset(ta.Token.String()) {
 		sdk.Abort("intent asset not supported")
 	}
-	if input.To == *creator {
+	if input.To == *creator || input.To == from {
 		sdk.Abort("receiver must differ from sender")
 	}
+	if refund == input.To || refund == input.Arbitrator {
+		sdk.Abort("refund address must not be receiver or arbitrator")
+	}
+	if input.Cofund && (input.From != "" || input.Refund != "") {
+		sdk.Abort("co-funded escrows cannot be sponsored")
+	}
+	if input.Heartbeat > 0 && input.Bond > 0 {
+		sdk.Abort("heartbeat and bond cannot be combined")
+	}
+	if input.Heartbeat > 0 && input.Cofund {
+		sdk.Abort("heartbeat and co-funding cannot be combined")
+	}
+	if input.Deposit > 0 && (input.Heartbeat > 0 || input.Cofund) {
+		sdk.Abort("deposit cannot be combined with heartbeat or co-funding")
+	}
+	if input.Units != nil {
+		if input.Heartbeat > 0 || input.Cofund || input.Deposit > 0 {
+			sdk.Abort("units cannot be combined with heartbeat, co-funding or deposit")
+		}
+		if input.Units.Cap > ta.LimitMilli/input.Units.Price {
+			sdk.Abort("unit cap exceeds the escrowed amount")
+		}
+	}
+	if input.Penalty != nil && (input.Heartbeat > 0 || input.Deposit > 0 || input.Units != nil) {
+		sdk.Abort("late penalty cannot be combined with heartbeat, deposit or units")
+	}
+	if input.After != nil {
+		if input.Heartbeat > 0 || input.Deposit > 0 || input.Units != nil {
+			sdk.Abort("dependency cannot be combined with heartbeat, deposit or units")
+		}
+		validateDependency(*input.After)
+	}
+	validateReceivers(input.Receivers, from, input.Arbitrator)
+	if input.Withdraw {
+		requireAssetOp(ta.Token.String(), AssetOpWithdraw)
+	}
 
 	// Lock funds into escrow as per the transfer.allow intent.
-	sdk.HiveDraw(int64(ta.LimitMilli), ta.Token)
+	drawFunds(ta.LimitMilli, ta.Token.String())
 
-	// Persist base escrow name.
-	saveEscrowBase(escrowID, input.Name)
+	initEscrow(escrowID, input.Name, from, input.To, input.Arbitrator, ta.LimitMilli, ta.Token.String())
 
-	// Persist roles as a compact pipe-delimited string: from|to|arb.
-	var sb strings.Builder
-	sb.Grow(len(*creator) + len(input.To) + len(input.Arbitrator) + 2)
-	sb.WriteString(*creator)
-	sb.WriteByte('|')
-	sb.WriteString(input.To)
-	sb.WriteByte('|')
-	sb.WriteString(input.Arbitrator)
-	saveEscrowParties(escrowID, sb.String())
+	// Optional settings are reported as extra attributes of the creation event.
+	extra := map[string]string{}
 
-	// Persist reward (amount + asset).
-	saveEscrowReward(escrowID, ta.LimitMilli, ta.Token.String())
+	// Sponsored escrows are funded by an account other than the voting sender.
+	if from != *creator {
+		extra["fd"] = *creator
+	}
+	if refund != from {
+		saveRefundAddress(escrowID, refund)
+		extra["rf"] = refund
+	}
 
-	// Initialize decisions (unset for all three parties).
-	saveEscrowDecisions(escrowID, []uint8{DecisionUnset, DecisionUnset, DecisionUnset})
+	// Hold the escrow inactive until the receiver posts the bond.
+	if input.Bond > 0 {
+		saveEscrowBond(escrowID, input.Bond, input.BondShare)
+		saveEscrowStatus(escrowID, StatusFunding)
+		extra["bo"] = strconv.FormatFloat(float64(input.Bond)/1000, 'f', -1, 64)
+	}
+
+	// Split releases across several receivers; To stays the receiver-side voter.
+	if len(input.Receivers) > 0 {
+		saveEscrowReceivers(escrowID, input.Receivers)
+		extra["rcv"] = formatReceivers(input.Receivers)
+	}
+
+	// Co-funded escrows track the creator as first funder.
+	if input.Cofund {
+		saveEscrowKind(escrowID, KindCofunded)
+		addBackerContribution(escrowID, *creator, ta.LimitMilli)
+		extra["k"] = KindCofunded
+	}
+
+	// Deposits return to the payer minus deductions claimed until the deadline.
+	if input.Deposit > 0 {
+		saveEscrowKind(escrowID, KindDeposit)
+		saveEscrowDeadline(escrowID, input.Deposit)
+		extra["k"] = KindDeposit
+		extra["dl"] = strconv.FormatUint(input.Deposit, 10)
+	}
+
+	// Payouts to hive: addresses are withdrawn to Hive L1 instead of transferred.
+	if input.Withdraw {
+		saveWithdrawDefault(escrowID)
+		extra["wd"] = "1"
+	}
+
+	// Integrations are notified once the escrow closes.
+	if input.Callback != nil {
+		saveEscrowCallback(escrowID, *input.Callback)
+		extra["cb"] = input.Callback.Contract + ":" + input.Callback.Method + ":" + input.Callback.Policy
+	}
+
+	// Decisions wait for the escrow this one depends on.
+	if input.After != nil {
+		saveEscrowDependency(escrowID, *input.After)
+		extra["after"] = formatDependency(*input.After)
+	}
+
+	// Receiver assignments complete after an objection window instead of consent.
+	if input.Assign > 0 {
+		saveAssignWindow(escrowID, input.Assign)
+		extra["asg"] = strconv.FormatUint(input.Assign, 10)
+	}
+
+	// Late releases are reduced by the penalty schedule.
+	if input.Penalty != nil {
+		savePenaltyTerms(escrowID, *input.Penalty)
+		extra["late"] = strconv.FormatUint(input.Penalty.Due, 10) + ":" +
+			strconv.FormatUint(input.Penalty.Rate, 10) + ":" +
+			strconv.FormatUint(input.Penalty.Interval, 10) + ":" +
+			strconv.FormatUint(input.Penalty.Cap, 10)
+	}
+
+	// Metered escrows release per confirmed unit until expiry.
+	if input.Units != nil {
+		saveEscrowKind(escrowID, KindMetered)
+		saveUnitTerms(escrowID, *input.Units)
+		saveEscrowDeadline(escrowID, input.Expiry)
+		extra["k"] = KindMetered
+		extra["pr"] = strconv.FormatFloat(float64(input.Units.Price)/1000, 'f', -1, 64)
+		extra["cap"] = strconv.FormatUint(input.Units.Cap, 10)
+		extra["dl"] = strconv.FormatUint(input.Expiry, 10)
+	}
+
+	// Dead-man switches start with a heartbeat at creation.
+	if input.Heartbeat > 0 {
+		saveEscrowKind(escrowID, KindHeartbeat)
+		saveEscrowHeartbeat(escrowID, EscrowHeartbeat{Interval: input.Heartbeat, Last: currentBlockHeight()})
+		extra["k"] = KindHeartbeat
+		extra["hb"] = strconv.FormatUint(input.Heartbeat, 10)
+	}
 
 	// Emit creation event and return escrow ID.
 	txID := sdk.GetEnvKey("tx.id")
 	EmitEscrowCreatedEvent(
 		escrowID,
-		*creator,
+		from,
 		input.To,
 		input.Arbitrator,
 		float64(ta.LimitMilli)/1000,
-		ta.Token.String(), *txID)
+		ta.Token.String(), extra, *txID)
 
 	result := strconv.FormatUint(escrowID, 10)
 	return &result
@@ -216,23 +443,52 @@ func CreateEscrow(payload *string) *string {
 func AddDecision(payload *string) *string {
 	input := CsvToDecisionArgs(payload)
 	roles := loadRoles(input.EscrowID)
-	sender := sdk.GetEnvKey("msg.sender")
+	sender := actingAddress()
 
 	role := getRoleOfSender(sender, roles)
+
+	// Co-funders (including the creator) vote on the sender side by funded weight.
+	weighted := loadKind(input.EscrowID) == KindCofunded && (role == nil || *role == 0)
+	if weighted {
+		if loadBackerContribution(input.EscrowID, *sender) == 0 {
+			sdk.Abort("sender not part of the escrow")
+		}
+		senderRole := uint8(0)
+		role = &senderRole
+	}
 	if role == nil {
 		sdk.Abort("sender not part of the escrow")
 	}
 
+	// Wager results are declared, not voted; deposits pay out by deduction lines, metered escrows by units.
+	switch loadKind(input.EscrowID) {
+	case KindWager:
+		sdk.Abort("wager results are declared by the arbitrator")
+	case KindDeposit:
+		sdk.Abort("deposits pay out by deduction claims")
+	case KindMetered:
+		sdk.Abort("metered escrows pay out by confirmed units")
+	}
+
+	// Disallow voting before the escrow holds its funds.
+	if loadStatus(input.EscrowID) != StatusActive {
+		sdk.Abort("escrow not funded")
+	}
+
 	decs := loadDecisions(input.EscrowID)
 
 	// Disallow voting on a closed escrow.
-	if closed, _ := getEscrowOutcome(decs); closed {
+	if closed, _ := loadEscrowOutcome(input.EscrowID, decs); closed {
 		sdk.Abort("escrow already closed")
 	}
 
 	// Record this sender's decision in their role slot.
 	roleIndex := *role
 	decs[roleIndex] = input.Decision
+	if weighted {
+		saveFunderDecision(input.EscrowID, *sender, input.Decision)
+		decs[0] = aggregateFunderDecision(input.EscrowID)
+	}
 
 	// Persist decision updates and process possible outcome.
 	saveEscrowDecisions(input.EscrowID, decs)
@@ -247,6 +503,36 @@ func AddDecision(payload *string) *string {
 	return nil
 }
 
+// CancelEscrow lets the sender withdraw an escrow whose receiver has not posted the bond
+// or, for wagers, has not staked yet. Dead-man switches can be cancelled while the heartbeat is current,
+// metered escrows once expired without open disputes.
+//
+//go:wasmexport e_cancel
+func CancelEscrow(id *string) *string {
+	escrowID := StringToUInt64(id)
+	roles := loadRoles(escrowID)
+	sender := actingAddress()
+	if *sender != roles[0] {
+		sdk.Abort("only the sender can cancel")
+	}
+	if closed, _ := loadEscrowOutcome(escrowID, loadDecisions(escrowID)); closed {
+		sdk.Abort("escrow already closed")
+	}
+
+	bond := loadBondTerms(escrowID)
+	bondPending := bond != nil && !bond.Posted
+	stakePending := loadKind(escrowID) == KindWager && loadStatus(escrowID) == StatusFunding
+	hb := loadEscrowHeartbeat(escrowID)
+	alive := hb != nil && heartbeatCurrent(hb)
+	if !bondPending && !stakePending && !alive && !meteredExpired(escrowID) {
+		sdk.Abort("escrow cannot be cancelled")
+	}
+
+	txID := sdk.GetEnvKey("tx.id")
+	finalizeEscrow(escrowID, DecisionRefund, *txID)
+	return nil
+}
+
 // GetEscrow returns escrow details by ID.
 //
 //go:wasmexport e_get
@@ -259,7 +545,7 @@ func GetEscrow(id *string) *string {
 	escrowParties := loadRoles(uintId)
 	am, as := loadReward(uintId)
 	escrowDecisions := loadDecisions(uintId)
-	c, o := getEscrowOutcome(escrowDecisions)
+	c, o := loadEscrowOutcome(uintId, escrowDecisions)
 	escrow := &Escrow{
 		ID:   uintId,
 		Name: *escrowBase,
@@ -279,6 +565,41 @@ func GetEscrow(id *string) *string {
 		Asset:   as,
 		Closed:  c,
 		Outcome: o,
+		Kind:    loadKind(uintId),
+		Status:  loadStatus(uintId),
+	}
+	escrow.Subscription = loadEscrowSubscription(uintId)
+	escrow.Bond = loadEscrowBond(uintId)
+	escrow.Heartbeat = loadEscrowHeartbeat(uintId)
+	escrow.Receivers = loadEscrowReceivers(uintId)
+	escrow.Released = float64(loadReleased(uintId)) / 1000
+	escrow.Penalty = loadEscrowPenalty(uintId)
+	escrow.After = loadEscrowDependency(uintId)
+	escrow.Parent = loadEscrowParent(uintId)
+	escrow.Children = loadEscrowChildren(uintId)
+	escrow.Rotations = loadRotationHistory(uintId)
+	escrow.Refund = loadRefundAddress(uintId)
+	escrow.Payouts = loadEscrowPayouts(uintId)
+	escrow.Withdraw = loadWithdrawDefault(uintId)
+	escrow.Callback = loadEscrowCallback(uintId)
+	if !c {
+		escrow.Forward = loadEscrowForward(uintId)
+		escrow.Assignment = loadAssignment(uintId)
+		escrow.Offers = loadEscrowOffers(uintId)
+	}
+	switch escrow.Kind {
+	case KindCrowdfund:
+		fillCrowdfund(escrow)
+	case KindCofunded:
+		fillBackers(escrow)
+	case KindInvoice:
+		fillInvoice(escrow)
+	case KindWager:
+		fillWager(escrow)
+	case KindDeposit:
+		fillDeposit(escrow)
+	case KindMetered:
+		fillMetered(escrow)
 	}
 
 	jsonStr := ToJSON(escrow, "escrow")
@@ -289,6 +610,28 @@ func GetEscrow(id *string) *string {
 // State Persistence & Loading
 // =====================
 
+// initEscrow persists name, parties, reward and unset decisions of a new escrow.
+func initEscrow(escrowID uint64, name string, from string, to string, arb string, amount uint64, asset string) {
+	// Persist base escrow name.
+	saveEscrowBase(escrowID, name)
+
+	// Persist roles as a compact pipe-delimited string: from|to|arb.
+	var sb strings.Builder
+	sb.Grow(len(from) + len(to) + len(arb) + 2)
+	sb.WriteString(from)
+	sb.WriteByte('|')
+	sb.WriteString(to)
+	sb.WriteByte('|')
+	sb.WriteString(arb)
+	saveEscrowParties(escrowID, sb.String())
+
+	// Persist reward (amount + asset).
+	saveEscrowReward(escrowID, amount, asset)
+
+	// Initialize decisions (unset for all three parties).
+	saveEscrowDecisions(escrowID, []uint8{DecisionUnset, DecisionUnset, DecisionUnset})
+}
+
 // saveEscrowBase stores the base escrow name and increments the global counter.
 func saveEscrowBase(escrowID uint64, escrowCsv string) error {
 	key := strconv.FormatUint(escrowID, 10)
@@ -324,6 +667,54 @@ func saveEscrowDecisions(escrowID uint64, decs []uint8) error {
 	return nil
 }
 
+// saveEscrowKind stores the escrow kind; standard escrows are not stored.
+func saveEscrowKind(escrowID uint64, kind string) {
+	key := strconv.FormatUint(escrowID, 10) + "|k"
+	sdk.StateSetObject(key, kind)
+}
+
+// saveEscrowStatus stores the funding status of an escrow.
+func saveEscrowStatus(escrowID uint64, status string) {
+	key := strconv.FormatUint(escrowID, 10) + "|s"
+	sdk.StateSetObject(key, status)
+}
+
+// saveEscrowDeadline stores the deadline block height of an escrow.
+func saveEscrowDeadline(escrowID uint64, height uint64) {
+	key := strconv.FormatUint(escrowID, 10) + "|e"
+	sdk.StateSetObject(key, strconv.FormatUint(height, 10))
+}
+
+// loadKind retrieves the escrow kind; defaults to standard if unset.
+func loadKind(escrowID uint64) string {
+	key := strconv.FormatUint(escrowID, 10) + "|k"
+	ptr := sdk.StateGetObject(key)
+	if ptr == nil || *ptr == "" {
+		return KindStandard
+	}
+	return *ptr
+}
+
+// loadStatus retrieves the funding status; defaults to active if unset.
+func loadStatus(escrowID uint64) string {
+	key := strconv.FormatUint(escrowID, 10) + "|s"
+	ptr := sdk.StateGetObject(key)
+	if ptr == nil || *ptr == "" {
+		return StatusActive
+	}
+	return *ptr
+}
+
+// loadDeadline retrieves the deadline block height; 0 means no deadline.
+func loadDeadline(escrowID uint64) uint64 {
+	key := strconv.FormatUint(escrowID, 10) + "|e"
+	ptr := sdk.StateGetObject(key)
+	if ptr == nil || *ptr == "" {
+		return 0
+	}
+	return StringToUInt64(ptr)
+}
+
 // loadRoles retrieves the from|to|arb addresses for an escrow.
 func loadRoles(escrowID uint64) []string {
 	key := strconv.FormatUint(escrowID, 10) + "|p"
@@ -381,8 +772,8 @@ func (c *CreateEscrowArgs) Validate(callerAddress string) {
 	if c.Arbitrator == "" {
 		sdk.Abort("arbitrator is mandatory")
 	}
-	// Arbitrator must be neutral and not overlap with participants.
-	if c.Arbitrator == c.To || c.Arbitrator == callerAddress {
+	// Arbitrator must be neutral and not overlap with participants, including the funder of a sponsored escrow.
+	if c.Arbitrator == c.To || c.Arbitrator == callerAddress || c.Arbitrator == c.From {
 		sdk.Abort("arbitrator must be 3rd party")
 	}
 }
@@ -391,6 +782,17 @@ func (c *CreateEscrowArgs) Validate(callerAddress string) {
 // Common Helpers
 // =====================
 
+// actingAddress returns the address acting in this call. When another contract calls the escrow,
+// that contract (msg.caller) acts and the originating user has no authority; otherwise the
+// transaction sender acts.
+func actingAddress() *string {
+	caller := sdk.GetEnvKey("msg.caller")
+	if caller != nil && sdk.Address(*caller).Domain() == sdk.AddressDomainContract {
+		return caller
+	}
+	return sdk.GetEnvKey("msg.sender")
+}
+
 // getRoleOfSender returns the role index (0=from,1=to,2=arb) of the sender, if any.
 func getRoleOfSender(sender *string, parties []string) *uint8 {
 	if sender == nil {
@@ -423,6 +825,23 @@ func getEscrowOutcome(decs []uint8) (bool, uint8) {
 	return false, DecisionUnset
 }
 
+// loadEscrowOutcome determines whether the escrow is closed and its outcome.
+// A persisted outcome takes precedence over the party decisions.
+func loadEscrowOutcome(escrowID uint64, decs []uint8) (bool, uint8) {
+	key := strconv.FormatUint(escrowID, 10) + "|o"
+	ptr := sdk.StateGetObject(key)
+	if ptr != nil && *ptr != "" {
+		return true, (*ptr)[0]
+	}
+	return getEscrowOutcome(decs)
+}
+
+// saveEscrowOutcome persists the final outcome of a closed escrow.
+func saveEscrowOutcome(escrowID uint64, outcome uint8) {
+	key := strconv.FormatUint(escrowID, 10) + "|o"
+	sdk.StateSetObject(key, string([]byte{outcome}))
+}
+
 // loadReward retrieves the escrow amount (milli) and asset.
 func loadReward(escrowID uint64) (amount uint64, asset string) {
 	key := strconv.FormatUint(escrowID, 10) + "|r"
@@ -448,19 +867,66 @@ func friendlyRoleName(r uint8) string {
 // processEscrowOutcome finalizes transfers and emits a close event when consensus is reached.
 func processEscrowOutcome(escrowID uint64, decs []uint8, txId string) {
 	if closed, outcome := getEscrowOutcome(decs); closed {
-		am, as := loadReward(escrowID)
-		r := loadRoles(escrowID)
-
-		// Route funds based on outcome consensus.
-		switch outcome {
-		case DecisionRefund:
-			sdk.HiveTransfer(sdk.Address(r[0]), int64(am), sdk.Asset(as)) // creator
-		case DecisionRelease:
-			sdk.HiveTransfer(sdk.Address(r[1]), int64(am), sdk.Asset(as)) // receiver
+		if outcome == DecisionRelease {
+			requireDependencyMet(escrowID)
+		}
+		finalizeEscrow(escrowID, outcome, txId)
+	}
+}
+
+// finalizeEscrow pays out the escrow for the given outcome, persists it and emits a close event.
+func finalizeEscrow(escrowID uint64, outcome uint8, txId string) {
+	am, as := loadReward(escrowID)
+	paid := am
+	r := loadRoles(escrowID)
+
+	details := map[string]string{}
+
+	// Route funds based on outcome consensus.
+	switch outcome {
+	case DecisionRefund:
+		refundToSender(escrowID, am, as, r[0])
+	case DecisionRelease:
+		// Late deliveries pay the penalty back to the sender.
+		if penalty := latePenalty(escrowID, am); penalty > 0 {
+			refundToSender(escrowID, penalty, as, r[0])
+			am -= penalty
+			details["pen"] = strconv.FormatFloat(float64(penalty)/1000, 'f', -1, 64)
 		}
+		// Forwarded shares fund a child escrow instead of paying the receiver.
+		if am > 0 {
+			if childID := releaseToReceivers(escrowID, am, as, r[1]); childID > 0 {
+				details["ch"] = strconv.FormatUint(childID, 10)
+			}
+		}
+	}
+
+	for k, v := range settleBond(escrowID, outcome, r, as) {
+		details[k] = v
+	}
+	closeEscrow(escrowID, outcome, paid, details, txId)
+}
 
-		EmitEscrowClosedEvent(escrowID, friendlyOutcome(outcome), txId)
+// closeEscrow persists the outcome of a paid out escrow, emits its close event
+// and notifies its close callback.
+func closeEscrow(escrowID uint64, outcome uint8, paid uint64, details map[string]string, txID string) {
+	if details == nil {
+		details = map[string]string{}
 	}
+	addPayoutDetails(escrowID, outcome, details)
+	saveEscrowOutcome(escrowID, outcome)
+	EmitEscrowClosedEvent(escrowID, friendlyOutcome(outcome), details, txID)
+	notifyClose(escrowID, outcome, paid, details, txID)
+}
+
+// refundToSender pays an amount back to the sender side of an escrow.
+// Escrows with backers refund them pro-rata instead of the creator; sponsored escrows refund the refund address.
+func refundToSender(escrowID uint64, amount uint64, asset string, from string) {
+	if len(loadBackers(escrowID)) > 0 {
+		refundBackers(escrowID, amount, asset)
+		return
+	}
+	paySender(escrowID, from, amount, asset)
 }
 
 // friendlyOutcome returns a human-readable outcome label.
@@ -470,6 +936,10 @@ func friendlyOutcome(o uint8) string {
 		return "f"
 	case DecisionRelease:
 		return "r"
+	case OutcomeVoid:
+		return "v"
+	case OutcomeSettled:
+		return "s"
 	default:
 		return "p"
 	}
@@ -496,6 +966,27 @@ func StringToUInt64(ptr *string) uint64 {
 	return val
 }
 
+// currentBlockHeight returns the block height of the running transaction.
+func currentBlockHeight() uint64 {
+	return StringToUInt64(sdk.GetEnvKey("block.height"))
+}
+
+// fundingExpired reports whether an escrow is still awaiting funds after its deadline.
+func fundingExpired(escrowID uint64) bool {
+	if loadStatus(escrowID) != StatusFunding {
+		return false
+	}
+	deadline := loadDeadline(escrowID)
+	return deadline > 0 && currentBlockHeight() > deadline
+}
+
+// mulDiv returns a*b/c without intermediate overflow; b must not exceed c.
+func mulDiv(a, b, c uint64) uint64 {
+	hi, lo := bits.Mul64(a, b)
+	q, _ := bits.Div64(hi, lo, c)
+	return q
+}
+
 // newEscrowID reads the next escrow ID from state; defaults to 0 if unset.
 func newEscrowID() uint64 {
 	ptr := sdk.StateGetObject("cnt:e")
@@ -520,10 +1011,13 @@ type TransferAllow struct {
 	Token      sdk.Asset
 }
 
-var validAssets = []string{sdk.AssetHbd.String(), sdk.AssetHive.String()}
+var validAssets = []string{sdk.AssetHbd.String(), sdk.AssetHive.String(), sdk.AssetHbdSavings.String(), sdk.AssetHiveCons.String()}
 
-// isValidAsset checks the token against supported assets.
+// isValidAsset checks the token against supported native assets and contract tokens.
 func isValidAsset(token string) bool {
+	if isTokenAsset(token) {
+		return true
+	}
 	for _, a := range validAssets {
 		if token == a {
 			return true
@@ -629,15 +1123,21 @@ func emitEvent(eventType string, attributes map[string]string, txID string) {
 }
 
 // EmitEscrowCreatedEvent emits an event for a newly created escrow.
-func EmitEscrowCreatedEvent(escrowID uint64, fromAddress string, toAddress string, arbAddress string, amount float64, asset string, txID string) {
-	emitEvent("cr", map[string]string{
+// Extra attributes are added to (or override) the standard ones.
+func EmitEscrowCreatedEvent(escrowID uint64, fromAddress string, toAddress string, arbAddress string, amount float64, asset string, extra map[string]string, txID string) {
+	attributes := map[string]string{
 		"id":  strconv.FormatUint(escrowID, 10),
+		"k":   KindStandard,
 		"f":   fromAddress,
 		"t":   toAddress,
 		"arb": arbAddress,
 		"am":  strconv.FormatFloat(amount, 'f', -1, 64),
 		"as":  asset,
-	}, txID)
+	}
+	for k, v := range extra {
+		attributes[k] = v
+	}
+	emitEvent("cr", attributes, txID)
 }
 
 // EmitEscrowDecisionEvent emits an event for a new decision.
@@ -651,9 +1151,14 @@ func EmitEscrowDecisionEvent(escrowID uint64, role string, address string, decis
 }
 
 // EmitEscrowClosedEvent emits an event for a closed escrow.
-func EmitEscrowClosedEvent(escrowID uint64, outcome string, txID string) {
-	emitEvent("cl", map[string]string{
+// Details describe additional payouts of the close.
+func EmitEscrowClosedEvent(escrowID uint64, outcome string, details map[string]string, txID string) {
+	attributes := map[string]string{
 		"id": strconv.FormatUint(escrowID, 10),
 		"o":  outcome,
-	}, txID)
+	}
+	for k, v := range details {
+		attributes[k] = v
+	}
+	emitEvent("cl", attributes, txID)
 }
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"okinoko_escrow/sdk"
+	"strconv"
+	"strings"
+)
+
+// =====================
+// Metered Releases
+// =====================
+
+// EscrowUnits describes the unit terms and progress of a metered escrow.
+type EscrowUnits struct {
+	Price     float64 `json:"pr"`
+	Cap       uint64  `json:"cap"`
+	Confirmed uint64  `json:"cf"`
+	Disputed  uint64  `json:"ds"`
+}
+
+// unitTerms holds the stored unit price (milli), unit cap, confirmed and disputed units.
+type unitTerms struct {
+	Price     uint64
+	Cap       uint64
+	Confirmed uint64
+	Disputed  uint64
+}
+
+// parseUnitsOption parses the units option value (price:cap:expiryBlock).
+func parseUnitsOption(value string) (unitTerms, uint64) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 3 {
+		sdk.Abort("invalid units: expected price:cap:expiryBlock")
+	}
+	price, err := strconv.ParseUint(parts[0], 10, 64)
+	if err != nil || price == 0 {
+		sdk.Abort("invalid unit price: must be a milli amount >0")
+	}
+	limit, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil || limit == 0 {
+		sdk.Abort("invalid unit cap: must be >0")
+	}
+	expiry, err := strconv.ParseUint(parts[2], 10, 64)
+	if err != nil || expiry <= currentBlockHeight() {
+		sdk.Abort("invalid expiry: must be a future block height")
+	}
+	return unitTerms{Price: price, Cap: limit}, expiry
+}
+
+// ConfirmUnits lets the sender confirm delivered units (EscrowID|Units) and releases units × price.
+//
+//go:wasmexport e_confirm_units
+func ConfirmUnits(payload *string) *string {
+	escrowID, units := csvToUnits(payload)
+	terms := requireUnexpiredMetered(escrowID)
+
+	sender := actingAddress()
+	roles := loadRoles(escrowID)
+	if *sender != roles[0] {
+		sdk.Abort("only the sender can confirm units")
+	}
+	if terms.Confirmed+terms.Disputed+units > terms.Cap {
+		sdk.Abort("units exceed the unit cap")
+	}
+
+	terms.Confirmed += units
+	txID := sdk.GetEnvKey("tx.id")
+	amount := releaseUnits(escrowID, terms, units, roles)
+	EmitUnitsConfirmedEvent(escrowID, units, float64(amount)/1000, terms.Confirmed, *txID)
+	closeMeteredIfComplete(escrowID, terms, roles, *txID)
+	return nil
+}
+
+// DisputeUnits lets the receiver claim delivered units the sender did not confirm (EscrowID|Units).
+//
+//go:wasmexport e_dispute_units
+func DisputeUnits(payload *string) *string {
+	escrowID, units := csvToUnits(payload)
+	terms := requireUnexpiredMetered(escrowID)
+
+	sender := actingAddress()
+	if *sender != loadRoles(escrowID)[1] {
+		sdk.Abort("only the receiver can dispute units")
+	}
+	if terms.Confirmed+terms.Disputed+units > terms.Cap {
+		sdk.Abort("units exceed the unit cap")
+	}
+
+	terms.Disputed += units
+	saveUnitTerms(escrowID, terms)
+
+	txID := sdk.GetEnvKey("tx.id")
+	EmitUnitsDisputedEvent(escrowID, units, terms.Disputed, *txID)
+	return nil
+}
+
+// RuleUnits lets the arbitrator award disputed units (EscrowID|AwardedUnits).
+// Awarded units are released; all other disputed units are dismissed.
+// Rulings are possible after expiry, so disputes open at expiry do not block the sender's cancel.
+//
+//go:wasmexport e_rule_units
+func RuleUnits(payload *string) *string {
+	escrowID, units := csvToUnitsAllowZero(payload)
+	terms := requireOpenMetered(escrowID)
+
+	sender := actingAddress()
+	roles := loadRoles(escrowID)
+	if *sender != roles[2] {
+		sdk.Abort("only the arbitrator can rule on units")
+	}
+	if terms.Disputed == 0 {
+		sdk.Abort("no disputed units")
+	}
+	if units > terms.Disputed {
+		sdk.Abort("award exceeds the disputed units")
+	}
+
+	terms.Disputed = 0
+	terms.Confirmed += units
+	txID := sdk.GetEnvKey("tx.id")
+	amount := releaseUnits(escrowID, terms, units, roles)
+	EmitUnitsRuledEvent(escrowID, units, float64(amount)/1000, terms.Confirmed, *txID)
+	closeMeteredIfComplete(escrowID, terms, roles, *txID)
+	return nil
+}
+
+// =====================
+// Metered Helpers
+// =====================
+
+// csvToUnits parses EscrowID|Units with units >0.
+func csvToUnits(payload *string) (uint64, uint64) {
+	escrowID, units := csvToUnitsAllowZero(payload)
+	if units == 0 {
+		sdk.Abort("invalid units: must be >0")
+	}
+	return escrowID, units
+}
+
+// csvToUnitsAllowZero parses EscrowID|Units.
+func csvToUnitsAllowZero(payload *string) (uint64, uint64) {
+	if payload == nil || *payload == "" {
+		sdk.Abort("input CSV is nil or empty")
+	}
+	idStr, unitsStr, found := strings.Cut(*payload, "|")
+	if !found {
+		sdk.Abort("invalid CSV format: expected EscrowID|Units")
+	}
+	units, err := strconv.ParseUint(unitsStr, 10, 64)
+	if err != nil {
+		sdk.Abort("invalid units: must be a number")
+	}
+	return StringToUInt64(&idStr), units
+}
+
+// requireOpenMetered aborts unless the escrow is an active and open metered escrow.
+func requireOpenMetered(escrowID uint64) unitTerms {
+	if loadKind(escrowID) != KindMetered {
+		sdk.Abort("escrow is not metered")
+	}
+	if loadStatus(escrowID) != StatusActive {
+		sdk.Abort("escrow not active")
+	}
+	if closed, _ := loadEscrowOutcome(escrowID, loadDecisions(escrowID)); closed {
+		sdk.Abort("escrow already closed")
+	}
+	return loadUnitTerms(escrowID)
+}
+
+// requireUnexpiredMetered aborts unless the escrow is an active, open and unexpired metered escrow.
+func requireUnexpiredMetered(escrowID uint64) unitTerms {
+	terms := requireOpenMetered(escrowID)
+	if currentBlockHeight() > loadDeadline(escrowID) {
+		sdk.Abort("escrow expired")
+	}
+	return terms
+}
+
+// meteredExpired reports whether a metered escrow expired without open disputes.
+func meteredExpired(escrowID uint64) bool {
+	if loadKind(escrowID) != KindMetered || currentBlockHeight() <= loadDeadline(escrowID) {
+		return false
+	}
+	return loadUnitTerms(escrowID).Disputed == 0
+}
+
+// releaseUnits pays units × price to the receiver side and persists the updated terms.
+func releaseUnits(escrowID uint64, terms unitTerms, units uint64, roles []string) uint64 {
+	saveUnitTerms(escrowID, terms)
+	amount := units * terms.Price
+	if amount == 0 {
+		return 0
+	}
+	remaining, asset := loadReward(escrowID)
+	if amount > remaining {
+		sdk.Abort("escrow balance too low for units")
+	}
+	releaseToReceivers(escrowID, amount, asset, roles[1])
+	saveEscrowReward(escrowID, remaining-amount, asset)
+	saveReleased(escrowID, loadReleased(escrowID)+amount)
+	return amount
+}
+
+// closeMeteredIfComplete closes the escrow once all units are confirmed and refunds any surplus.
+func closeMeteredIfComplete(escrowID uint64, terms unitTerms, roles []string, txID string) {
+	if terms.Confirmed < terms.Cap {
+		return
+	}
+	remaining, asset := loadReward(escrowID)
+	if remaining > 0 {
+		refundToSender(escrowID, remaining, asset, roles[0])
+		saveEscrowReward(escrowID, 0, asset)
+	}
+	details := settleBond(escrowID, DecisionRelease, roles, asset)
+	closeEscrow(escrowID, DecisionRelease, remaining, details, txID)
+}
+
+// saveUnitTerms stores price|cap|confirmed|disputed of a metered escrow.
+func saveUnitTerms(escrowID uint64, terms unitTerms) {
+	key := strconv.FormatUint(escrowID, 10) + "|u"
+	buf := make([]byte, 0, 64)
+	buf = strconv.AppendUint(buf, terms.Price, 10)
+	buf = append(buf, '|')
+	buf = strconv.AppendUint(buf, terms.Cap, 10)
+	buf = append(buf, '|')
+	buf = strconv.AppendUint(buf, terms.Confirmed, 10)
+	buf = append(buf, '|')
+	buf = strconv.AppendUint(buf, terms.Disputed, 10)
+	sdk.StateSetObject(key, string(buf))
+}
+
+// loadUnitTerms retrieves the unit terms of a metered escrow.
+func loadUnitTerms(escrowID uint64) unitTerms {
+	key := strconv.FormatUint(escrowID, 10) + "|u"
+	ptr := sdk.StateGetObject(key)
+	if ptr == nil || *ptr == "" {
+		sdk.Abort("unit terms not found")
+	}
+	parts := strings.Split(*ptr, "|")
+	if len(parts) != 4 {
+		sdk.Abort("invalid unit terms")
+	}
+	return unitTerms{
+		Price:     StringToUInt64(&parts[0]),
+		Cap:       StringToUInt64(&parts[1]),
+		Confirmed: StringToUInt64(&parts[2]),
+		Disputed:  StringToUInt64(&parts[3]),
+	}
+}
+
+// fillMetered adds the expiry and the unit terms to an escrow view.
+func fillMetered(escrow *Escrow) {
+	terms := loadUnitTerms(escrow.ID)
+	escrow.Deadline = loadDeadline(escrow.ID)
+	escrow.Units = &EscrowUnits{
+		Price:     float64(terms.Price) / 1000,
+		Cap:       terms.Cap,
+		Confirmed: terms.Confirmed,
+		Disputed:  terms.Disputed,
+	}
+}
+
+// EmitUnitsConfirmedEvent emits an event for units confirmed by the sender.
+func EmitUnitsConfirmedEvent(escrowID uint64, units uint64, amount float64, confirmed uint64, txID string) {
+	emitEvent("uc", map[string]string{
+		"id": strconv.FormatUint(escrowID, 10),
+		"u":  strconv.FormatUint(units, 10),
+		"am": strconv.FormatFloat(amount, 'f', -1, 64),
+		"cf": strconv.FormatUint(confirmed, 10),
+	}, txID)
+}
+
+// EmitUnitsDisputedEvent emits an event for units disputed by the receiver.
+func EmitUnitsDisputedEvent(escrowID uint64, units uint64, disputed uint64, txID string) {
+	emitEvent("ud", map[string]string{
+		"id": strconv.FormatUint(escrowID, 10),
+		"u":  strconv.FormatUint(units, 10),
+		"ds": strconv.FormatUint(disputed, 10),
+	}, txID)
+}
+
+// EmitUnitsRuledEvent emits an event for disputed units awarded by the arbitrator.
+func EmitUnitsRuledEvent(escrowID uint64, units uint64, amount float64, confirmed uint64, txID string) {
+	emitEvent("ur", map[string]string{
+		"id": strconv.FormatUint(escrowID, 10),
+		"u":  strconv.FormatUint(units, 10),
+		"am": strconv.FormatFloat(amount, 'f', -1, 64),
+		"cf": strconv.FormatUint(confirmed, 10),
+	}, txID)
+}
@@ -0,0 +1,223 @@
+package main
+
+import (
+	"okinoko_escrow/sdk"
+	"strconv"
+	"strings"
+)
+
+// =====================
+// Settlement Offers
+// =====================
+
+// EscrowOffer describes a pending settlement offer of sender or receiver.
+type EscrowOffer struct {
+	By     string `json:"by"`
+	Share  uint64 `json:"sh"`
+	Expiry uint64 `json:"ex"`
+}
+
+// offerRoles are the roles that can make settlement offers.
+var offerRoles = []uint8{0, 1}
+
+// MakeOffer proposes a settlement split (EscrowID|ReceiverShareBps|ExpiryBlock).
+// The share is the part of the escrow paid to the receiver side; the rest goes back to the sender.
+// A new offer replaces the caller's pending offer.
+//
+//go:wasmexport e_offer
+func MakeOffer(payload *string) *string {
+	if payload == nil || *payload == "" {
+		sdk.Abort("input CSV is nil or empty")
+	}
+	parts := strings.Split(*payload, "|")
+	if len(parts) != 3 {
+		sdk.Abort("invalid CSV format: expected EscrowID|ReceiverShareBps|ExpiryBlock")
+	}
+	escrowID := StringToUInt64(&parts[0])
+	share, err := strconv.ParseUint(parts[1], 10, 64)
+	if err != nil || share > maxBasisPoints {
+		sdk.Abort("invalid share: must be 0-10000 basis points")
+	}
+	expiry, err := strconv.ParseUint(parts[2], 10, 64)
+	if err != nil || expiry <= currentBlockHeight() {
+		sdk.Abort("invalid expiry: must be a future block height")
+	}
+	requireSettleable(escrowID)
+
+	sender := actingAddress()
+	role := offerRole(*sender, loadRoles(escrowID))
+	saveOffer(escrowID, role, share, expiry)
+
+	txID := sdk.GetEnvKey("tx.id")
+	EmitOfferEvent(escrowID, friendlyRoleName(role), share, expiry, *txID)
+	return nil
+}
+
+// WithdrawOffer removes the caller's pending settlement offer.
+//
+//go:wasmexport e_withdraw_offer
+func WithdrawOffer(id *string) *string {
+	escrowID := StringToUInt64(id)
+	requireSettleable(escrowID)
+
+	sender := actingAddress()
+	role := offerRole(*sender, loadRoles(escrowID))
+	if _, _, found := loadOffer(escrowID, role); !found {
+		sdk.Abort("no pending offer")
+	}
+	deleteOffer(escrowID, role)
+
+	txID := sdk.GetEnvKey("tx.id")
+	EmitOfferWithdrawnEvent(escrowID, friendlyRoleName(role), *txID)
+	return nil
+}
+
+// AcceptOffer accepts the other side's settlement offer (EscrowID|ReceiverShareBps).
+// The share must match the offer; the escrow closes with the agreed split.
+//
+//go:wasmexport e_accept_offer
+func AcceptOffer(payload *string) *string {
+	if payload == nil || *payload == "" {
+		sdk.Abort("input CSV is nil or empty")
+	}
+	idStr, shareStr, found := strings.Cut(*payload, "|")
+	if !found {
+		sdk.Abort("invalid CSV format: expected EscrowID|ReceiverShareBps")
+	}
+	escrowID := StringToUInt64(&idStr)
+	requireSettleable(escrowID)
+
+	sender := actingAddress()
+	roles := loadRoles(escrowID)
+	other := 1 - offerRole(*sender, roles)
+	share, expiry, found := loadOffer(escrowID, other)
+	if !found || expiry < currentBlockHeight() {
+		sdk.Abort("no open offer")
+	}
+	if share != StringToUInt64(&shareStr) {
+		sdk.Abort("share does not match offer")
+	}
+
+	am, as := loadReward(escrowID)
+	toReceiver := mulDiv(am, share, maxBasisPoints)
+	if toReceiver > 0 {
+		requireDependencyMet(escrowID)
+	}
+	toSender := am - toReceiver
+	details := map[string]string{
+		"sh": strconv.FormatUint(share, 10),
+		"tf": strconv.FormatFloat(float64(toSender)/1000, 'f', -1, 64),
+		"tt": strconv.FormatFloat(float64(toReceiver)/1000, 'f', -1, 64),
+	}
+	if toReceiver > 0 {
+		if childID := releaseToReceivers(escrowID, toReceiver, as, roles[1]); childID > 0 {
+			details["ch"] = strconv.FormatUint(childID, 10)
+		}
+	}
+	if toSender > 0 {
+		refundToSender(escrowID, toSender, as, roles[0])
+	}
+	for _, r := range offerRoles {
+		deleteOffer(escrowID, r)
+	}
+
+	for k, v := range settleBond(escrowID, OutcomeSettled, roles, as) {
+		details[k] = v
+	}
+	txID := sdk.GetEnvKey("tx.id")
+	closeEscrow(escrowID, OutcomeSettled, am, details, *txID)
+	return nil
+}
+
+// =====================
+// Offer Helpers
+// =====================
+
+// requireSettleable aborts unless the escrow is active, open and neither a wager nor co-funded.
+// Co-funded money moves only by the weighted funder vote, never by the creator alone.
+func requireSettleable(escrowID uint64) {
+	switch loadKind(escrowID) {
+	case KindWager:
+		sdk.Abort("wagers cannot be settled by offer")
+	case KindCofunded:
+		sdk.Abort("co-funded escrows cannot be settled by offer")
+	}
+	if loadStatus(escrowID) != StatusActive {
+		sdk.Abort("escrow not active")
+	}
+	if closed, _ := loadEscrowOutcome(escrowID, loadDecisions(escrowID)); closed {
+		sdk.Abort("escrow already closed")
+	}
+}
+
+// offerRole returns the role of an address; only sender and receiver can make offers.
+func offerRole(address string, roles []string) uint8 {
+	role := getRoleOfSender(&address, roles)
+	if role == nil || *role > 1 {
+		sdk.Abort("only sender and receiver can settle")
+	}
+	return *role
+}
+
+// offerKey returns the state key of the offer made by a role.
+func offerKey(escrowID uint64, role uint8) string {
+	return strconv.FormatUint(escrowID, 10) + "|of|" + friendlyRoleName(role)
+}
+
+// saveOffer stores share|expiry of the offer made by a role.
+func saveOffer(escrowID uint64, role uint8, share uint64, expiry uint64) {
+	buf := make([]byte, 0, 32)
+	buf = strconv.AppendUint(buf, share, 10)
+	buf = append(buf, '|')
+	buf = strconv.AppendUint(buf, expiry, 10)
+	sdk.StateSetObject(offerKey(escrowID, role), string(buf))
+}
+
+// loadOffer retrieves share and expiry of the offer made by a role.
+func loadOffer(escrowID uint64, role uint8) (uint64, uint64, bool) {
+	ptr := sdk.StateGetObject(offerKey(escrowID, role))
+	if ptr == nil || *ptr == "" {
+		return 0, 0, false
+	}
+	share, expiry, found := strings.Cut(*ptr, "|")
+	if !found {
+		sdk.Abort("invalid offer data")
+	}
+	return StringToUInt64(&share), StringToUInt64(&expiry), true
+}
+
+// deleteOffer removes the offer made by a role.
+func deleteOffer(escrowID uint64, role uint8) {
+	sdk.StateDeleteObject(offerKey(escrowID, role))
+}
+
+// loadEscrowOffers returns the unexpired offers of an open escrow.
+func loadEscrowOffers(escrowID uint64) []EscrowOffer {
+	var offers []EscrowOffer
+	height := currentBlockHeight()
+	for _, r := range offerRoles {
+		share, expiry, found := loadOffer(escrowID, r)
+		if found && expiry >= height {
+			offers = append(offers, EscrowOffer{By: friendlyRoleName(r), Share: share, Expiry: expiry})
+		}
+	}
+	return offers
+}
+
+// EmitOfferEvent emits an event for a settlement offer.
+func EmitOfferEvent(escrowID uint64, role string, share uint64, expiry uint64, txID string) {
+	emitEvent("of", map[string]string{
+		"id": strconv.FormatUint(escrowID, 10),
+		"r":  role,
+		"sh": strconv.FormatUint(share, 10),
+		"ex": strconv.FormatUint(expiry, 10),
+	}, txID)
+}
+
+// EmitOfferWithdrawnEvent emits an event for a withdrawn settlement offer.
+func EmitOfferWithdrawnEvent(escrowID uint64, role string, txID string) {
+	emitEvent("ow", map[string]string{
+		"id": strconv.FormatUint(escrowID, 10),
+		"r":  role,
+	}, txID)
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"okinoko_escrow/sdk"
+	"strconv"
+	"strings"
+)
+
+// =====================
+// Partial Releases
+// =====================
+
+// ReleasePartial lets the sender release part of the escrow to the receiver (EscrowID|Amount).
+// The amount is given in milli units and must leave a remainder, which stays in escrow
+// and still covers the open units of a metered escrow or the claimed deductions of a deposit.
+//
+//go:wasmexport e_release_partial
+func ReleasePartial(payload *string) *string {
+	if payload == nil || *payload == "" {
+		sdk.Abort("input CSV is nil or empty")
+	}
+	idStr, amountStr, found := strings.Cut(*payload, "|")
+	if !found {
+		sdk.Abort("invalid CSV format: expected EscrowID|Amount")
+	}
+	escrowID := StringToUInt64(&idStr)
+	amount, err := strconv.ParseUint(amountStr, 10, 64)
+	if err != nil || amount == 0 {
+		sdk.Abort("invalid amount: must be a milli amount >0")
+	}
+
+	// Wagers have no sender side to release; co-funded money moves only by the weighted funder vote.
+	switch loadKind(escrowID) {
+	case KindWager:
+		sdk.Abort("wagers cannot be released partially")
+	case KindCofunded:
+		sdk.Abort("co-funded escrows cannot be released partially")
+	}
+	if loadStatus(escrowID) != StatusActive {
+		sdk.Abort("escrow not active")
+	}
+	if closed, _ := loadEscrowOutcome(escrowID, loadDecisions(escrowID)); closed {
+		sdk.Abort("escrow already closed")
+	}
+
+	sender := actingAddress()
+	roles := loadRoles(escrowID)
+	if *sender != roles[0] {
+		sdk.Abort("only the sender can release partially")
+	}
+	requireDependencyMet(escrowID)
+
+	remaining, asset := loadReward(escrowID)
+	if amount >= remaining {
+		sdk.Abort("amount must be below the escrowed amount")
+	}
+	if remaining-amount < minimumAmount(escrowID) {
+		sdk.Abort("remainder below the open units or claimed deductions")
+	}
+
+	releaseToReceivers(escrowID, amount, asset, roles[1])
+	saveEscrowReward(escrowID, remaining-amount, asset)
+	released := loadReleased(escrowID) + amount
+	saveReleased(escrowID, released)
+
+	txID := sdk.GetEnvKey("tx.id")
+	EmitPartialReleaseEvent(escrowID, float64(amount)/1000, float64(released)/1000, float64(remaining-amount)/1000, *txID)
+	return nil
+}
+
+// saveReleased stores the total amount released partially so far.
+func saveReleased(escrowID uint64, amount uint64) {
+	key := strconv.FormatUint(escrowID, 10) + "|rp"
+	sdk.StateSetObject(key, strconv.FormatUint(amount, 10))
+}
+
+// loadReleased retrieves the total amount released partially so far.
+func loadReleased(escrowID uint64) uint64 {
+	key := strconv.FormatUint(escrowID, 10) + "|rp"
+	ptr := sdk.StateGetObject(key)
+	if ptr == nil || *ptr == "" {
+		return 0
+	}
+	return StringToUInt64(ptr)
+}
+
+// EmitPartialReleaseEvent emits an event for a partial release to the receiver.
+func EmitPartialReleaseEvent(escrowID uint64, amount float64, released float64, remaining float64, txID string) {
+	emitEvent("rp", map[string]string{
+		"id":  strconv.FormatUint(escrowID, 10),
+		"am":  strconv.FormatFloat(amount, 'f', -1, 64),
+		"rel": strconv.FormatFloat(released, 'f', -1, 64),
+		"rem": strconv.FormatFloat(remaining, 'f', -1, 64),
+	}, txID)
+}
@@ -0,0 +1,255 @@
+package main
+
+import (
+	"okinoko_escrow/sdk"
+	"strconv"
+	"strings"
+)
+
+// =====================
+// Payout Preferences
+// =====================
+
+const (
+	// PayoutTransfer credits the destination's VSC balance.
+	PayoutTransfer = "t"
+	// PayoutWithdraw unmaps the funds to the Hive L1 account of a hive: destination.
+	PayoutWithdraw = "w"
+)
+
+// EscrowPayout describes where and how a party is paid.
+type EscrowPayout struct {
+	Method  string `json:"m"`
+	Address string `json:"a"`
+}
+
+// parseWithdrawOption parses the withdraw option value (0/1).
+func parseWithdrawOption(value string) bool {
+	switch value {
+	case "1":
+		return true
+	case "0":
+		return false
+	default:
+		sdk.Abort("invalid withdraw option: must be 0/1")
+	}
+	return false
+}
+
+// SetPayout lets a party register its payout destination (EscrowID|Method|Address).
+// Method t transfers to any VSC user or contract address, w withdraws to a Hive account.
+// EscrowID|w withdraws to the party's own address; without method and address the preference is removed.
+//
+//go:wasmexport e_payout_to
+func SetPayout(payload *string) *string {
+	if payload == nil || *payload == "" {
+		sdk.Abort("input CSV is nil or empty")
+	}
+	parts := strings.Split(*payload, "|")
+	if len(parts) > 3 {
+		sdk.Abort("invalid CSV format: expected EscrowID|Method|Address")
+	}
+	escrowID := StringToUInt64(&parts[0])
+	requireOpenEscrow(escrowID)
+
+	sender := actingAddress()
+	role := getRoleOfSender(sender, loadRoles(escrowID))
+	if role == nil {
+		sdk.Abort("only parties can set a payout")
+	}
+	if *role == 0 && len(loadBackers(escrowID)) > 0 {
+		sdk.Abort("backed escrows refund their backers directly")
+	}
+	if *role == 0 && loadRefundAddress(escrowID) != "" {
+		sdk.Abort("refunds go to the refund address")
+	}
+	if *role == 1 && len(loadEscrowReceivers(escrowID)) > 0 {
+		sdk.Abort("escrows with multiple receivers pay their shares directly")
+	}
+
+	txID := sdk.GetEnvKey("tx.id")
+	if len(parts) == 1 {
+		deletePayout(escrowID, *role)
+		EmitPayoutEvent(escrowID, friendlyRoleName(*role), EscrowPayout{}, *txID)
+		return nil
+	}
+	po := EscrowPayout{Method: parts[1], Address: *sender}
+	if po.Method == PayoutWithdraw {
+		_, asset := loadReward(escrowID)
+		requireAssetOp(asset, AssetOpWithdraw)
+	}
+	if len(parts) == 3 {
+		po.Address = parts[2]
+		validatePayout(po)
+	} else if po.Method != PayoutWithdraw {
+		sdk.Abort("invalid CSV format: expected EscrowID|Method|Address")
+	}
+	savePayout(escrowID, *role, po)
+
+	EmitPayoutEvent(escrowID, friendlyRoleName(*role), po, *txID)
+	return nil
+}
+
+// =====================
+// Payout Helpers
+// =====================
+
+// validatePayout aborts unless the destination can receive payouts with the method.
+func validatePayout(po EscrowPayout) {
+	addr := sdk.Address(po.Address)
+	switch po.Method {
+	case PayoutTransfer:
+		if addr.Domain() == sdk.AddressDomainSystem || (addr.Domain() == sdk.AddressDomainUser && !addr.IsValid()) {
+			sdk.Abort("invalid payout address")
+		}
+	case PayoutWithdraw:
+		if addr.Type() != sdk.AddressTypeHive {
+			sdk.Abort("withdrawals need a hive: address")
+		}
+	default:
+		sdk.Abort("invalid payout method: must be t/w")
+	}
+}
+
+// payoutTarget returns the destination of a payout to the given role.
+func payoutTarget(escrowID uint64, role uint8, address string) EscrowPayout {
+	po := loadPayout(escrowID, role)
+	if po == nil {
+		return defaultTarget(escrowID, address)
+	}
+	if po.Method == PayoutWithdraw {
+		return withdrawOrTransfer(po.Address)
+	}
+	return *po
+}
+
+// defaultTarget returns the destination of an address without preference.
+// Escrows created with the withdraw option withdraw to hive: addresses.
+func defaultTarget(escrowID uint64, address string) EscrowPayout {
+	if loadWithdrawDefault(escrowID) {
+		return withdrawOrTransfer(address)
+	}
+	return EscrowPayout{Method: PayoutTransfer, Address: address}
+}
+
+// withdrawOrTransfer withdraws to hive: addresses and falls back to a transfer for other address types.
+func withdrawOrTransfer(address string) EscrowPayout {
+	if sdk.Address(address).Type() == sdk.AddressTypeHive {
+		return EscrowPayout{Method: PayoutWithdraw, Address: address}
+	}
+	return EscrowPayout{Method: PayoutTransfer, Address: address}
+}
+
+// payParty pays an amount to the payout destination of a role.
+func payParty(escrowID uint64, role uint8, address string, amount uint64, asset string) {
+	sendPayout(payoutTarget(escrowID, role, address), amount, asset)
+}
+
+// sendPayout transfers or withdraws an amount to a payout destination.
+func sendPayout(po EscrowPayout, amount uint64, asset string) {
+	if payoutMethod(po, asset) == PayoutWithdraw {
+		requireAssetOp(asset, AssetOpWithdraw)
+		sdk.HiveWithdraw(sdk.Address(po.Address), int64(amount), sdk.Asset(asset))
+		return
+	}
+	sendFunds(po.Address, amount, asset)
+}
+
+// payoutMethod returns the method actually used for a destination; contract tokens and staked assets cannot be withdrawn.
+func payoutMethod(po EscrowPayout, asset string) string {
+	if !isWithdrawable(asset) {
+		return PayoutTransfer
+	}
+	return po.Method
+}
+
+// senderTarget returns the destination of sender-side payouts; a refund address takes precedence.
+func senderTarget(escrowID uint64, from string) EscrowPayout {
+	if address := loadRefundAddress(escrowID); address != "" {
+		return defaultTarget(escrowID, address)
+	}
+	return payoutTarget(escrowID, 0, from)
+}
+
+// paySender pays an amount to the sender side of an escrow.
+func paySender(escrowID uint64, from string, amount uint64, asset string) {
+	sendPayout(senderTarget(escrowID, from), amount, asset)
+}
+
+// addPayoutDetails reports destination (pf, pt) and method (mf, mt) of the sides paid for an outcome.
+// Sides paid to backers or multiple receivers are not reported.
+func addPayoutDetails(escrowID uint64, outcome uint8, details map[string]string) {
+	roles := loadRoles(escrowID)
+	_, asset := loadReward(escrowID)
+	if outcome != DecisionRelease && len(loadBackers(escrowID)) == 0 {
+		po := senderTarget(escrowID, roles[0])
+		details["pf"], details["mf"] = po.Address, payoutMethod(po, asset)
+	}
+	if outcome != DecisionRefund && len(loadEscrowReceivers(escrowID)) == 0 {
+		po := payoutTarget(escrowID, 1, roles[1])
+		details["pt"], details["mt"] = po.Address, payoutMethod(po, asset)
+	}
+}
+
+// saveWithdrawDefault marks an escrow to withdraw payouts to Hive accounts.
+func saveWithdrawDefault(escrowID uint64) {
+	sdk.StateSetObject(strconv.FormatUint(escrowID, 10)+"|wd", "1")
+}
+
+// loadWithdrawDefault reports whether an escrow withdraws payouts to Hive accounts.
+func loadWithdrawDefault(escrowID uint64) bool {
+	ptr := sdk.StateGetObject(strconv.FormatUint(escrowID, 10) + "|wd")
+	return ptr != nil && *ptr == "1"
+}
+
+// payoutKey returns the state key of the payout preference of a role.
+func payoutKey(escrowID uint64, role uint8) string {
+	return strconv.FormatUint(escrowID, 10) + "|po|" + friendlyRoleName(role)
+}
+
+// savePayout stores method|address of a payout preference.
+func savePayout(escrowID uint64, role uint8, po EscrowPayout) {
+	sdk.StateSetObject(payoutKey(escrowID, role), po.Method+"|"+po.Address)
+}
+
+// loadPayout retrieves the payout preference of a role; nil if there is none.
+func loadPayout(escrowID uint64, role uint8) *EscrowPayout {
+	ptr := sdk.StateGetObject(payoutKey(escrowID, role))
+	if ptr == nil || *ptr == "" {
+		return nil
+	}
+	method, address, found := strings.Cut(*ptr, "|")
+	if !found {
+		sdk.Abort("invalid payout data")
+	}
+	return &EscrowPayout{Method: method, Address: address}
+}
+
+// deletePayout removes the payout preference of a role.
+func deletePayout(escrowID uint64, role uint8) {
+	sdk.StateDeleteObject(payoutKey(escrowID, role))
+}
+
+// loadEscrowPayouts returns the payout preferences of an escrow keyed by role label.
+func loadEscrowPayouts(escrowID uint64) map[string]EscrowPayout {
+	var payouts map[string]EscrowPayout
+	for r := uint8(0); r < 3; r++ {
+		if po := loadPayout(escrowID, r); po != nil {
+			if payouts == nil {
+				payouts = map[string]EscrowPayout{}
+			}
+			payouts[friendlyRoleName(r)] = *po
+		}
+	}
+	return payouts
+}
+
+// EmitPayoutEvent emits an event for a registered or removed payout preference.
+func EmitPayoutEvent(escrowID uint64, role string, po EscrowPayout, txID string) {
+	emitEvent("po", map[string]string{
+		"id": strconv.FormatUint(escrowID, 10),
+		"r":  role,
+		"m":  po.Method,
+		"a":  po.Address,
+	}, txID)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"okinoko_escrow/sdk"
+	"strconv"
+	"strings"
+)
+
+// =====================
+// Late-Delivery Penalties
+// =====================
+
+// EscrowPenalty describes the late-delivery penalty schedule of an escrow.
+type EscrowPenalty struct {
+	Due       uint64 `json:"due"`
+	Rate      uint64 `json:"bps"`
+	Interval  uint64 `json:"iv"`
+	Cap       uint64 `json:"cap"`
+	Delivered uint64 `json:"dv,omitempty"`
+}
+
+// penaltyTerms holds the stored penalty schedule and the delivery height (0 until delivered).
+type penaltyTerms struct {
+	Due       uint64
+	Rate      uint64
+	Interval  uint64
+	Cap       uint64
+	Delivered uint64
+}
+
+// parsePenaltyOption parses the late option value (due:bpsPerInterval:intervalBlocks:capBps).
+func parsePenaltyOption(value string) penaltyTerms {
+	parts := strings.Split(value, ":")
+	if len(parts) != 4 {
+		sdk.Abort("invalid late penalty: expected due:bps:interval:cap")
+	}
+	nums := make([]uint64, 4)
+	for i, p := range parts {
+		n, err := strconv.ParseUint(p, 10, 64)
+		if err != nil {
+			sdk.Abort("invalid late penalty: fields must be numbers")
+		}
+		nums[i] = n
+	}
+	terms := penaltyTerms{Due: nums[0], Rate: nums[1], Interval: nums[2], Cap: nums[3]}
+	if terms.Due <= currentBlockHeight() {
+		sdk.Abort("invalid late penalty: due must be a future block height")
+	}
+	if terms.Rate == 0 || terms.Interval == 0 {
+		sdk.Abort("invalid late penalty: bps and interval must be >0")
+	}
+	if terms.Cap == 0 || terms.Cap > maxBasisPoints {
+		sdk.Abort("invalid late penalty: cap must be 1-10000 basis points")
+	}
+	return terms
+}
+
+// MarkDelivered lets the receiver mark the delivery, which stops the penalty clock.
+//
+//go:wasmexport e_delivered
+func MarkDelivered(id *string) *string {
+	escrowID := StringToUInt64(id)
+	terms := loadPenaltyTerms(escrowID)
+	if terms == nil {
+		sdk.Abort("escrow has no late penalty")
+	}
+	if terms.Delivered > 0 {
+		sdk.Abort("delivery already marked")
+	}
+	if closed, _ := loadEscrowOutcome(escrowID, loadDecisions(escrowID)); closed {
+		sdk.Abort("escrow already closed")
+	}
+
+	sender := actingAddress()
+	if *sender != loadRoles(escrowID)[1] {
+		sdk.Abort("only the receiver can mark the delivery")
+	}
+
+	terms.Delivered = currentBlockHeight()
+	savePenaltyTerms(escrowID, *terms)
+
+	txID := sdk.GetEnvKey("tx.id")
+	EmitDeliveredEvent(escrowID, terms.Delivered, *txID)
+	return nil
+}
+
+// latePenalty returns the penalty (milli) on a release of amount.
+// Each started interval after the due height costs the rate, up to the cap;
+// the clock stops at the delivery marker or else at the current block.
+func latePenalty(escrowID uint64, amount uint64) uint64 {
+	terms := loadPenaltyTerms(escrowID)
+	if terms == nil {
+		return 0
+	}
+	stop := terms.Delivered
+	if stop == 0 {
+		stop = currentBlockHeight()
+	}
+	if stop <= terms.Due {
+		return 0
+	}
+	intervals := (stop-terms.Due-1)/terms.Interval + 1 // started intervals; cannot overflow for large intervals
+	bps := terms.Cap
+	if intervals <= terms.Cap/terms.Rate {
+		bps = intervals * terms.Rate
+	}
+	return mulDiv(amount, bps, maxBasisPoints)
+}
+
+// savePenaltyTerms stores due|bps|interval|cap|delivered of an escrow.
+func savePenaltyTerms(escrowID uint64, terms penaltyTerms) {
+	key := strconv.FormatUint(escrowID, 10) + "|lt"
+	buf := make([]byte, 0, 80)
+	buf = strconv.AppendUint(buf, terms.Due, 10)
+	buf = append(buf, '|')
+	buf = strconv.AppendUint(buf, terms.Rate, 10)
+	buf = append(buf, '|')
+	buf = strconv.AppendUint(buf, terms.Interval, 10)
+	buf = append(buf, '|')
+	buf = strconv.AppendUint(buf, terms.Cap, 10)
+	buf = append(buf, '|')
+	buf = strconv.AppendUint(buf, terms.Delivered, 10)
+	sdk.StateSetObject(key, string(buf))
+}
+
+// loadPenaltyTerms retrieves the penalty schedule of an escrow; nil if it has none.
+func loadPenaltyTerms(escrowID uint64) *penaltyTerms {
+	key := strconv.FormatUint(escrowID, 10) + "|lt"
+	ptr := sdk.StateGetObject(key)
+	if ptr == nil || *ptr == "" {
+		return nil
+	}
+	parts := strings.Split(*ptr, "|")
+	if len(parts) != 5 {
+		sdk.Abort("invalid penalty data")
+	}
+	return &penaltyTerms{
+		Due:       StringToUInt64(&parts[0]),
+		Rate:      StringToUInt64(&parts[1]),
+		Interval:  StringToUInt64(&parts[2]),
+		Cap:       StringToUInt64(&parts[3]),
+		Delivered: StringToUInt64(&parts[4]),
+	}
+}
+
+// loadEscrowPenalty returns the penalty view of an escrow; nil if it has none.
+func loadEscrowPenalty(escrowID uint64) *EscrowPenalty {
+	terms := loadPenaltyTerms(escrowID)
+	if terms == nil {
+		return nil
+	}
+	return &EscrowPenalty{
+		Due:       terms.Due,
+		Rate:      terms.Rate,
+		Interval:  terms.Interval,
+		Cap:       terms.Cap,
+		Delivered: terms.Delivered,
+	}
+}
+
+// EmitDeliveredEvent emits an event for a delivery marked by the receiver.
+func EmitDeliveredEvent(escrowID uint64, height uint64, txID string) {
+	emitEvent("dv", map[string]string{
+		"id": strconv.FormatUint(escrowID, 10),
+		"h":  strconv.FormatUint(height, 10),
+	}, txID)
+}
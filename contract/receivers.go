@@ -0,0 +1,150 @@
+package main
+
+import (
+	"okinoko_escrow/sdk"
+	"strconv"
+	"strings"
+)
+
+// =====================
+// Multiple Receivers
+// =====================
+
+// EscrowShare describes a receiver and its share of a release in basis points.
+type EscrowShare struct {
+	Address string `json:"a"`
+	Share   uint64 `json:"sh"`
+}
+
+// parseReceiversOption parses the receivers option value (addr:bps,addr:bps,...).
+// Shares must be >0 and sum up to 10000 basis points.
+func parseReceiversOption(value string) []EscrowShare {
+	entries := strings.Split(value, ",")
+	shares := make([]EscrowShare, 0, len(entries))
+	var total uint64
+	for _, e := range entries {
+		sep := strings.LastIndexByte(e, ':')
+		if sep <= 0 {
+			sdk.Abort("invalid receiver: expected address:bps")
+		}
+		bps, err := strconv.ParseUint(e[sep+1:], 10, 64)
+		if err != nil || bps == 0 || bps > maxBasisPoints {
+			sdk.Abort("invalid receiver share: must be 1-10000 basis points")
+		}
+		addr := e[:sep]
+		for _, s := range shares {
+			if s.Address == addr {
+				sdk.Abort("duplicate receiver " + addr)
+			}
+		}
+		shares = append(shares, EscrowShare{Address: addr, Share: bps})
+		total += bps
+	}
+	if total != maxBasisPoints {
+		sdk.Abort("receiver shares must sum to 10000 basis points")
+	}
+	return shares
+}
+
+// validateReceivers checks that neither sender nor arbitrator are among the receivers.
+func validateReceivers(shares []EscrowShare, sender string, arbitrator string) {
+	for _, s := range shares {
+		if s.Address == sender {
+			sdk.Abort("receiver must differ from sender")
+		}
+		if s.Address == arbitrator {
+			sdk.Abort("arbitrator must be 3rd party")
+		}
+	}
+}
+
+// validateParties checks changed parties of an existing escrow like CreateEscrow does: the seats must
+// stay distinct and neither receiver nor arbitrator may be the refund address, a backer or, for the
+// arbitrator and sender, one of multiple receivers.
+func validateParties(escrowID uint64, from string, to string, arb string) {
+	if to == from {
+		sdk.Abort("receiver must differ from sender")
+	}
+	if arb == from || arb == to {
+		sdk.Abort("arbitrator must be 3rd party")
+	}
+	if rf := loadRefundAddress(escrowID); rf != "" && (rf == to || rf == arb) {
+		sdk.Abort("refund address must not be receiver or arbitrator")
+	}
+	for _, b := range loadBackers(escrowID) {
+		if b == to || b == arb {
+			sdk.Abort("receiver and arbitrator cannot be backers")
+		}
+	}
+	validateReceivers(loadEscrowReceivers(escrowID), from, arb)
+}
+
+// releaseToReceivers pays an amount to the receiver side of an escrow and returns the child escrow ID
+// funded by a forward instruction of the receiver (0 if none).
+// With multiple receivers each gets its share; the rounding remainder goes to the first receiver.
+func releaseToReceivers(escrowID uint64, amount uint64, asset string, to string) uint64 {
+	forwarded, childID := forwardRelease(escrowID, amount, asset, to)
+	amount -= forwarded
+	shares := loadEscrowReceivers(escrowID)
+	if len(shares) == 0 {
+		if amount > 0 {
+			payParty(escrowID, 1, to, amount, asset)
+		}
+		return childID
+	}
+
+	parts := make([]uint64, len(shares))
+	var paid uint64
+	for i, s := range shares {
+		parts[i] = mulDiv(amount, s.Share, maxBasisPoints)
+		paid += parts[i]
+	}
+	parts[0] += amount - paid
+
+	for i, s := range shares {
+		if parts[i] > 0 {
+			sendPayout(defaultTarget(escrowID, s.Address), parts[i], asset)
+		}
+	}
+	return childID
+}
+
+// formatReceivers encodes receiver shares as addr:bps,addr:bps.
+func formatReceivers(shares []EscrowShare) string {
+	buf := make([]byte, 0, 32*len(shares))
+	for i, s := range shares {
+		if i > 0 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, s.Address...)
+		buf = append(buf, ':')
+		buf = strconv.AppendUint(buf, s.Share, 10)
+	}
+	return string(buf)
+}
+
+// saveEscrowReceivers stores the receiver shares of an escrow.
+func saveEscrowReceivers(escrowID uint64, shares []EscrowShare) {
+	key := strconv.FormatUint(escrowID, 10) + "|rc"
+	sdk.StateSetObject(key, formatReceivers(shares))
+}
+
+// loadEscrowReceivers retrieves the receiver shares of an escrow; nil for a single receiver.
+func loadEscrowReceivers(escrowID uint64) []EscrowShare {
+	key := strconv.FormatUint(escrowID, 10) + "|rc"
+	ptr := sdk.StateGetObject(key)
+	if ptr == nil || *ptr == "" {
+		return nil
+	}
+	entries := strings.Split(*ptr, ",")
+	shares := make([]EscrowShare, len(entries))
+	for i, e := range entries {
+		sep := strings.LastIndexByte(e, ':')
+		if sep <= 0 {
+			sdk.Abort("invalid receivers data")
+		}
+		bps := e[sep+1:]
+		shares[i] = EscrowShare{Address: e[:sep], Share: StringToUInt64(&bps)}
+	}
+	return shares
+}
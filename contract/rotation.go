@@ -0,0 +1,285 @@
+package main
+
+import (
+	"okinoko_escrow/sdk"
+	"strconv"
+	"strings"
+)
+
+// =====================
+// Party Rotation
+// =====================
+
+// rotationDelay is the number of blocks after which an unopposed rotation can be completed (~7 days).
+const rotationDelay = 201600
+
+// EscrowRotation describes a pending or completed rotation of a party address.
+type EscrowRotation struct {
+	Role   string `json:"r"`
+	Old    string `json:"o"`
+	New    string `json:"n"`
+	Height uint64 `json:"h"`
+}
+
+// pendingRotation holds a proposed rotation, its proposer and the approval bits of the roles.
+type pendingRotation struct {
+	New       string
+	Height    uint64
+	Approvals uint8 // bit per approving role (1<<role), including the proposer
+	By        uint8 // role of the proposer
+}
+
+// ProposeRotation proposes a replacement address for the caller's own seat (EscrowID|NewAddress)
+// or, e.g. after a lost key, for the seat of another party (EscrowID|Role|NewAddress).
+// A pending rotation of the seat cannot be replaced; it must be objected first.
+//
+//go:wasmexport e_rotate
+func ProposeRotation(payload *string) *string {
+	if payload == nil || *payload == "" {
+		sdk.Abort("input CSV is nil or empty")
+	}
+	parts := strings.Split(*payload, "|")
+	if len(parts) < 2 || len(parts) > 3 || parts[len(parts)-1] == "" {
+		sdk.Abort("invalid CSV format: expected EscrowID[|Role]|NewAddress")
+	}
+	escrowID := StringToUInt64(&parts[0])
+	newAddr := parts[len(parts)-1]
+	requireOpenEscrow(escrowID)
+
+	sender := actingAddress()
+	roles := loadRoles(escrowID)
+	proposer := getRoleOfSender(sender, roles)
+	if proposer == nil {
+		sdk.Abort("only parties can propose a rotation")
+	}
+	role := *proposer
+	if len(parts) == 3 {
+		role = parseRoleName(parts[1])
+	}
+	if loadRotation(escrowID, role) != nil {
+		sdk.Abort("rotation already pending")
+	}
+	if getRoleOfSender(&newAddr, roles) != nil {
+		sdk.Abort("new address already part of the escrow")
+	}
+	if role == 0 && len(loadBackers(escrowID)) > 0 {
+		sdk.Abort("sender of a backed escrow cannot rotate")
+	}
+	seats := append([]string(nil), roles...)
+	seats[role] = newAddr
+	validateParties(escrowID, seats[0], seats[1], seats[2])
+
+	saveRotation(escrowID, role, pendingRotation{New: newAddr, Height: currentBlockHeight(), Approvals: 1 << *proposer, By: *proposer})
+	txID := sdk.GetEnvKey("tx.id")
+	EmitRotationEvent("ro", escrowID, EscrowRotation{Role: friendlyRoleName(role), Old: roles[role], New: newAddr}, *txID)
+	return nil
+}
+
+// RespondRotation lets a party approve (y) or object (n) to a pending rotation (EscrowID|Role|y/n).
+// The rotated seat cannot respond, since its key may be the one that was lost or compromised.
+// The rotation applies once both other parties approved; an objection cancels it.
+//
+//go:wasmexport e_rotate_respond
+func RespondRotation(payload *string) *string {
+	if payload == nil || *payload == "" {
+		sdk.Abort("input CSV is nil or empty")
+	}
+	parts := strings.Split(*payload, "|")
+	if len(parts) != 3 || (parts[2] != "y" && parts[2] != "n") {
+		sdk.Abort("invalid CSV format: expected EscrowID|Role|y/n")
+	}
+	escrowID := StringToUInt64(&parts[0])
+	requireOpenEscrow(escrowID)
+	role := parseRoleName(parts[1])
+	rot := loadRotation(escrowID, role)
+	if rot == nil {
+		sdk.Abort("no pending rotation")
+	}
+
+	sender := actingAddress()
+	roles := loadRoles(escrowID)
+	responder := getRoleOfSender(sender, roles)
+	if responder == nil || *responder == rot.By || *responder == role {
+		sdk.Abort("only the other parties can respond")
+	}
+
+	txID := sdk.GetEnvKey("tx.id")
+	if parts[2] == "n" {
+		deleteRotation(escrowID, role)
+		EmitRotationEvent("rx", escrowID, EscrowRotation{Role: parts[1], Old: roles[role], New: rot.New}, *txID)
+		return nil
+	}
+	rot.Approvals |= 1 << *responder
+	if rot.Approvals|1<<role == 7 {
+		applyRotation(escrowID, role, rot.New, roles, *txID)
+		return nil
+	}
+	saveRotation(escrowID, role, *rot)
+	return nil
+}
+
+// CompleteRotation applies an unopposed rotation of the holder's own seat after the rotation delay
+// (EscrowID|Role); callable by anyone. A rotation proposed for another party's seat needs both approvals.
+//
+//go:wasmexport e_rotate_complete
+func CompleteRotation(payload *string) *string {
+	if payload == nil || *payload == "" {
+		sdk.Abort("input CSV is nil or empty")
+	}
+	idStr, roleStr, found := strings.Cut(*payload, "|")
+	if !found {
+		sdk.Abort("invalid CSV format: expected EscrowID|Role")
+	}
+	escrowID := StringToUInt64(&idStr)
+	requireOpenEscrow(escrowID)
+	role := parseRoleName(roleStr)
+	rot := loadRotation(escrowID, role)
+	if rot == nil {
+		sdk.Abort("no pending rotation")
+	}
+	if rot.By != role {
+		sdk.Abort("rotation not approved yet")
+	}
+	if currentBlockHeight() <= rot.Height+rotationDelay {
+		sdk.Abort("rotation delay not over")
+	}
+	txID := sdk.GetEnvKey("tx.id")
+	applyRotation(escrowID, role, rot.New, loadRoles(escrowID), *txID)
+	return nil
+}
+
+// =====================
+// Rotation Helpers
+// =====================
+
+// requireOpenEscrow aborts if the escrow is closed.
+func requireOpenEscrow(escrowID uint64) {
+	if closed, _ := loadEscrowOutcome(escrowID, loadDecisions(escrowID)); closed {
+		sdk.Abort("escrow already closed")
+	}
+}
+
+// parseRoleName maps a role label (f, t, arb) to its index.
+func parseRoleName(name string) uint8 {
+	switch name {
+	case "f":
+		return 0
+	case "t":
+		return 1
+	case "arb":
+		return 2
+	}
+	sdk.Abort("invalid role: must be f/t/arb")
+	return 0
+}
+
+// applyRotation replaces the address of a seat, resets its decision and records the history.
+// Everything the old address registered or confirmed for the seat is dropped, like on an assignment.
+// The new address must still be new to the escrow and keep the parties neutral.
+func applyRotation(escrowID uint64, role uint8, newAddr string, roles []string, txID string) {
+	if getRoleOfSender(&newAddr, roles) != nil {
+		sdk.Abort("new address already part of the escrow")
+	}
+	old := roles[role]
+	roles[role] = newAddr
+	validateParties(escrowID, roles[0], roles[1], roles[2])
+	saveEscrowParties(escrowID, strings.Join(roles, "|"))
+
+	decs := loadDecisions(escrowID)
+	decs[role] = DecisionUnset
+	saveEscrowDecisions(escrowID, decs)
+	deleteRotation(escrowID, role)
+	deletePayout(escrowID, role) // the preference belongs to the old address
+	if role < 2 {
+		deleteOffer(escrowID, role)
+	}
+	if role == 1 {
+		sdk.StateDeleteObject(strconv.FormatUint(escrowID, 10) + "|fw")
+		resetCallbackConsent(escrowID)
+	}
+	deleteAssignment(escrowID)
+	deleteAmendment(escrowID)
+
+	rot := EscrowRotation{Role: friendlyRoleName(role), Old: old, New: newAddr, Height: currentBlockHeight()}
+	appendRotationHistory(escrowID, rot)
+	EmitRotationEvent("rd", escrowID, rot, txID)
+}
+
+// rotationKey returns the state key of the pending rotation of a seat.
+func rotationKey(escrowID uint64, role uint8) string {
+	return strconv.FormatUint(escrowID, 10) + "|ro|" + friendlyRoleName(role)
+}
+
+// saveRotation stores new|height|approvals|proposer of a pending rotation.
+func saveRotation(escrowID uint64, role uint8, rot pendingRotation) {
+	buf := make([]byte, 0, 32+len(rot.New))
+	buf = append(buf, rot.New...)
+	buf = append(buf, '|')
+	buf = strconv.AppendUint(buf, rot.Height, 10)
+	buf = append(buf, '|')
+	buf = strconv.AppendUint(buf, uint64(rot.Approvals), 10)
+	buf = append(buf, '|')
+	buf = strconv.AppendUint(buf, uint64(rot.By), 10)
+	sdk.StateSetObject(rotationKey(escrowID, role), string(buf))
+}
+
+// loadRotation retrieves the pending rotation of a seat; nil if there is none.
+func loadRotation(escrowID uint64, role uint8) *pendingRotation {
+	ptr := sdk.StateGetObject(rotationKey(escrowID, role))
+	if ptr == nil || *ptr == "" {
+		return nil
+	}
+	parts := strings.Split(*ptr, "|")
+	if len(parts) != 4 {
+		sdk.Abort("invalid rotation data")
+	}
+	return &pendingRotation{
+		New:       parts[0],
+		Height:    StringToUInt64(&parts[1]),
+		Approvals: uint8(StringToUInt64(&parts[2])),
+		By:        uint8(StringToUInt64(&parts[3])),
+	}
+}
+
+// deleteRotation removes the pending rotation of a seat.
+func deleteRotation(escrowID uint64, role uint8) {
+	sdk.StateDeleteObject(rotationKey(escrowID, role))
+}
+
+// appendRotationHistory adds a completed rotation (role|old|new|height) to the history of an escrow.
+func appendRotationHistory(escrowID uint64, rot EscrowRotation) {
+	key := strconv.FormatUint(escrowID, 10) + "|rh"
+	entry := rot.Role + "|" + rot.Old + "|" + rot.New + "|" + strconv.FormatUint(rot.Height, 10)
+	if ptr := sdk.StateGetObject(key); ptr != nil && *ptr != "" {
+		entry = *ptr + "," + entry
+	}
+	sdk.StateSetObject(key, entry)
+}
+
+// loadRotationHistory retrieves the completed rotations of an escrow.
+func loadRotationHistory(escrowID uint64) []EscrowRotation {
+	ptr := sdk.StateGetObject(strconv.FormatUint(escrowID, 10) + "|rh")
+	if ptr == nil || *ptr == "" {
+		return nil
+	}
+	entries := strings.Split(*ptr, ",")
+	history := make([]EscrowRotation, len(entries))
+	for i, e := range entries {
+		parts := strings.Split(e, "|")
+		if len(parts) != 4 {
+			sdk.Abort("invalid rotation history")
+		}
+		history[i] = EscrowRotation{Role: parts[0], Old: parts[1], New: parts[2], Height: StringToUInt64(&parts[3])}
+	}
+	return history
+}
+
+// EmitRotationEvent emits a rotation event: ro (proposed), rx (objected) or rd (done).
+func EmitRotationEvent(eventType string, escrowID uint64, rot EscrowRotation, txID string) {
+	emitEvent(eventType, map[string]string{
+		"id": strconv.FormatUint(escrowID, 10),
+		"r":  rot.Role,
+		"o":  rot.Old,
+		"n":  rot.New,
+	}, txID)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"okinoko_escrow/sdk"
+	"strconv"
+)
+
+// =====================
+// Sponsored Escrows
+// =====================
+
+// resolveSponsorship returns the voting sender and the refund address of a new escrow.
+// The funder votes unless an explicit sender is given; refunds default to the funder.
+func resolveSponsorship(input CreateEscrowArgs, funder string) (string, string) {
+	from := funder
+	if input.From != "" {
+		from = input.From
+	}
+	refund := input.Refund
+	if refund == "" {
+		refund = funder
+	}
+	return from, refund
+}
+
+// saveRefundAddress stores the refund address of an escrow; refunds to the sender are not stored.
+func saveRefundAddress(escrowID uint64, address string) {
+	sdk.StateSetObject(strconv.FormatUint(escrowID, 10)+"|rf", address)
+}
+
+// loadRefundAddress retrieves the refund address of an escrow; empty if refunds go to the sender.
+func loadRefundAddress(escrowID uint64) string {
+	ptr := sdk.StateGetObject(strconv.FormatUint(escrowID, 10) + "|rf")
+	if ptr == nil {
+		return ""
+	}
+	return *ptr
+}
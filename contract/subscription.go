@@ -0,0 +1,455 @@
+package main
+
+import (
+	"fmt"
+	"okinoko_escrow/sdk"
+	"strconv"
+	"strings"
+)
+
+// =====================
+// Subscriptions
+// =====================
+
+// Subscription describes a recurring escrow and its child escrows.
+type Subscription struct {
+	ID           uint64   `json:"id"`
+	Name         string   `json:"n"`
+	From         string   `json:"f"`
+	To           string   `json:"t"`
+	Arbitrator   string   `json:"arb"`
+	Amount       float64  `json:"am"`
+	Asset        string   `json:"as"`
+	PeriodBlocks uint64   `json:"pb"`
+	Periods      uint64   `json:"pc"`
+	Start        uint64   `json:"st"`
+	Balance      float64  `json:"bal"`
+	Children     []uint64 `json:"ch"`
+	Stopped      bool     `json:"x"`
+}
+
+// CreateSubscriptionArgs are arguments to create a new subscription.
+type CreateSubscriptionArgs struct {
+	CreateEscrowArgs
+	Amount       uint64
+	PeriodBlocks uint64
+	Periods      uint64
+}
+
+// subscriptionConfig holds the stored terms of a subscription.
+type subscriptionConfig struct {
+	Amount       uint64
+	Asset        string
+	PeriodBlocks uint64
+	Periods      uint64
+	Start        uint64
+}
+
+// CsvToCreateSubscriptionArgs parses a pipe-delimited string into CreateSubscriptionArgs
+// (Name|To|Arbitrator|Amount|PeriodBlocks|Periods). Amount per period is given in milli units.
+func CsvToCreateSubscriptionArgs(csv *string) CreateSubscriptionArgs {
+	if csv == nil || *csv == "" {
+		sdk.Abort("input CSV is nil or empty")
+	}
+
+	parts := strings.Split(*csv, "|")
+	if len(parts) != 6 {
+		sdk.Abort("invalid CSV format: expected 6 fields (Name|To|Arbitrator|Amount|PeriodBlocks|Periods)")
+	}
+
+	amount, err := strconv.ParseUint(parts[3], 10, 64)
+	if err != nil {
+		sdk.Abort("invalid amount: must be a milli amount")
+	}
+	periodBlocks, err := strconv.ParseUint(parts[4], 10, 64)
+	if err != nil {
+		sdk.Abort("invalid period length: must be a number of blocks")
+	}
+	periods, err := strconv.ParseUint(parts[5], 10, 64)
+	if err != nil {
+		sdk.Abort("invalid periods: must be a number")
+	}
+
+	return CreateSubscriptionArgs{
+		CreateEscrowArgs: CreateEscrowArgs{
+			Name:       parts[0],
+			To:         parts[1],
+			Arbitrator: parts[2],
+		},
+		Amount:       amount,
+		PeriodBlocks: periodBlocks,
+		Periods:      periods,
+	}
+}
+
+// CreateSubscription creates a subscription funded by the caller's transfer.allow intent.
+// The first period escrow is created immediately.
+//
+//go:wasmexport e_create_sub
+func CreateSubscription(payload *string) *string {
+	input := CsvToCreateSubscriptionArgs(payload)
+	creator := actingAddress()
+
+	input.Validate(*creator)
+	if input.To == *creator {
+		sdk.Abort("receiver must differ from sender")
+	}
+	if input.Amount == 0 || input.PeriodBlocks == 0 || input.Periods == 0 {
+		sdk.Abort("amount, period length and periods must be >0")
+	}
+	if input.Periods > ^uint64(0)/input.Amount {
+		sdk.Abort("subscription total too large")
+	}
+
+	ta := GetFirstTransferAllow(sdk.GetEnv().Intents)
+	if ta == nil {
+		sdk.Abort("intent needed")
+	}
+	if ta.LimitMilli < input.Amount {
+		sdk.Abort("intent must cover at least one period")
+	}
+
+	// Hold at most the amount needed for all periods.
+	deposit := ta.LimitMilli
+	if total := input.Amount * input.Periods; deposit > total {
+		deposit = total
+	}
+	drawFunds(deposit, ta.Token.String())
+
+	subID := newSubscriptionID()
+	key := subscriptionKey(subID)
+	sdk.StateSetObject(key, input.Name)
+	sdk.StateSetObject(key+"|p", *creator+"|"+input.To+"|"+input.Arbitrator)
+	cfg := subscriptionConfig{
+		Amount:       input.Amount,
+		Asset:        ta.Token.String(),
+		PeriodBlocks: input.PeriodBlocks,
+		Periods:      input.Periods,
+		Start:        currentBlockHeight(),
+	}
+	saveSubscriptionConfig(subID, cfg)
+	saveSubscriptionBalance(subID, deposit)
+	setSubscriptionCount(subID + 1)
+
+	txID := sdk.GetEnvKey("tx.id")
+	EmitSubscriptionCreatedEvent(subID, *creator, input.To, input.Arbitrator, float64(input.Amount)/1000, cfg.Asset, input.PeriodBlocks, input.Periods, *txID)
+	createDuePeriods(subID, *txID)
+
+	result := strconv.FormatUint(subID, 10)
+	return &result
+}
+
+// DepositSubscription tops up the balance of a subscription from the sender's transfer.allow intent.
+//
+//go:wasmexport e_sub_deposit
+func DepositSubscription(id *string) *string {
+	subID := StringToUInt64(id)
+	roles := loadSubscriptionRoles(subID)
+	sender := actingAddress()
+	if *sender != roles[0] {
+		sdk.Abort("only the sender can deposit")
+	}
+	if subscriptionStopped(subID) {
+		sdk.Abort("subscription stopped")
+	}
+
+	cfg := loadSubscriptionConfig(subID)
+	ta := GetFirstTransferAllow(sdk.GetEnv().Intents)
+	if ta == nil {
+		sdk.Abort("intent needed")
+	}
+	if ta.Token.String() != cfg.Asset {
+		sdk.Abort("intent asset does not match subscription asset")
+	}
+
+	balance := loadSubscriptionBalance(subID)
+	needed := cfg.Amount*(cfg.Periods-uint64(len(loadSubscriptionChildren(subID)))) - balance
+	deposit := ta.LimitMilli
+	if deposit > needed {
+		deposit = needed
+	}
+	if deposit == 0 {
+		sdk.Abort("subscription already fully funded")
+	}
+	drawFunds(deposit, ta.Token.String())
+	saveSubscriptionBalance(subID, balance+deposit)
+
+	txID := sdk.GetEnvKey("tx.id")
+	EmitSubscriptionDepositEvent(subID, float64(deposit)/1000, *txID)
+	createDuePeriods(subID, *txID)
+	return nil
+}
+
+// TickSubscription creates the escrows of all due periods; callable by anyone.
+//
+//go:wasmexport e_sub_tick
+func TickSubscription(id *string) *string {
+	subID := StringToUInt64(id)
+	loadSubscriptionRoles(subID)
+	if subscriptionStopped(subID) {
+		sdk.Abort("subscription stopped")
+	}
+	txID := sdk.GetEnvKey("tx.id")
+	if createDuePeriods(subID, *txID) == 0 {
+		sdk.Abort("no period due or balance too low")
+	}
+	return nil
+}
+
+// StopSubscription stops future periods and refunds the remaining balance to the sender.
+// Either the sender or the receiver can stop a subscription.
+//
+//go:wasmexport e_sub_stop
+func StopSubscription(id *string) *string {
+	subID := StringToUInt64(id)
+	roles := loadSubscriptionRoles(subID)
+	sender := actingAddress()
+	if *sender != roles[0] && *sender != roles[1] {
+		sdk.Abort("only sender or receiver can stop")
+	}
+	if subscriptionStopped(subID) {
+		sdk.Abort("subscription already stopped")
+	}
+
+	// Periods that are already due are created before stopping.
+	txID := sdk.GetEnvKey("tx.id")
+	createDuePeriods(subID, *txID)
+
+	cfg := loadSubscriptionConfig(subID)
+	balance := loadSubscriptionBalance(subID)
+	if balance > 0 {
+		sendFunds(roles[0], balance, cfg.Asset)
+		saveSubscriptionBalance(subID, 0)
+	}
+	sdk.StateSetObject(subscriptionKey(subID)+"|x", strconv.FormatUint(currentBlockHeight(), 10))
+
+	EmitSubscriptionStoppedEvent(subID, *sender, float64(balance)/1000, *txID)
+	return nil
+}
+
+// GetSubscription returns subscription details by ID.
+//
+//go:wasmexport e_sub_get
+func GetSubscription(id *string) *string {
+	subID := StringToUInt64(id)
+	name := sdk.StateGetObject(subscriptionKey(subID))
+	if name == nil || *name == "" {
+		sdk.Abort(fmt.Sprintf("subscription %s not found", *id))
+	}
+	roles := loadSubscriptionRoles(subID)
+	cfg := loadSubscriptionConfig(subID)
+	sub := &Subscription{
+		ID:           subID,
+		Name:         *name,
+		From:         roles[0],
+		To:           roles[1],
+		Arbitrator:   roles[2],
+		Amount:       float64(cfg.Amount) / 1000,
+		Asset:        cfg.Asset,
+		PeriodBlocks: cfg.PeriodBlocks,
+		Periods:      cfg.Periods,
+		Start:        cfg.Start,
+		Balance:      float64(loadSubscriptionBalance(subID)) / 1000,
+		Children:     loadSubscriptionChildren(subID),
+		Stopped:      subscriptionStopped(subID),
+	}
+
+	jsonStr := ToJSON(sub, "subscription")
+	return &jsonStr
+}
+
+// =====================
+// Subscription Helpers
+// =====================
+
+// createDuePeriods creates a child escrow for every due and funded period.
+// It returns the number of created escrows.
+func createDuePeriods(subID uint64, txID string) int {
+	cfg := loadSubscriptionConfig(subID)
+	children := loadSubscriptionChildren(subID)
+	balance := loadSubscriptionBalance(subID)
+	height := currentBlockHeight()
+	roles := loadSubscriptionRoles(subID)
+	name := sdk.StateGetObject(subscriptionKey(subID))
+
+	created := 0
+	for uint64(len(children)) < cfg.Periods {
+		period := uint64(len(children))
+		if cfg.Start+period*cfg.PeriodBlocks > height || balance < cfg.Amount {
+			break
+		}
+		escrowID := newEscrowID()
+		initEscrow(escrowID, *name, roles[0], roles[1], roles[2], cfg.Amount, cfg.Asset)
+		sdk.StateSetObject(strconv.FormatUint(escrowID, 10)+"|sub", strconv.FormatUint(subID, 10))
+		balance -= cfg.Amount
+		children = append(children, escrowID)
+		created++
+
+		EmitEscrowCreatedEvent(escrowID, roles[0], roles[1], roles[2], float64(cfg.Amount)/1000, cfg.Asset, nil, txID)
+		EmitSubscriptionPeriodEvent(subID, period, escrowID, txID)
+	}
+
+	if created > 0 {
+		saveSubscriptionBalance(subID, balance)
+		ids := make([]string, len(children))
+		for i, c := range children {
+			ids[i] = strconv.FormatUint(c, 10)
+		}
+		sdk.StateSetObject(subscriptionKey(subID)+"|ch", strings.Join(ids, "|"))
+	}
+	return created
+}
+
+// loadEscrowSubscription returns the subscription an escrow belongs to, if any.
+func loadEscrowSubscription(escrowID uint64) *uint64 {
+	ptr := sdk.StateGetObject(strconv.FormatUint(escrowID, 10) + "|sub")
+	if ptr == nil || *ptr == "" {
+		return nil
+	}
+	subID := StringToUInt64(ptr)
+	return &subID
+}
+
+// subscriptionKey returns the base state key of a subscription.
+func subscriptionKey(subID uint64) string {
+	return "s:" + strconv.FormatUint(subID, 10)
+}
+
+// loadSubscriptionRoles retrieves the from|to|arb addresses of a subscription.
+func loadSubscriptionRoles(subID uint64) []string {
+	ptr := sdk.StateGetObject(subscriptionKey(subID) + "|p")
+	if ptr == nil || *ptr == "" {
+		sdk.Abort(fmt.Sprintf("subscription %d not found", subID))
+	}
+	roles := strings.Split(*ptr, "|")
+	if len(roles) != 3 {
+		sdk.Abort("invalid parties length")
+	}
+	return roles
+}
+
+// saveSubscriptionConfig stores amount|asset|periodBlocks|periods|start of a subscription.
+func saveSubscriptionConfig(subID uint64, cfg subscriptionConfig) {
+	buf := make([]byte, 0, 64+len(cfg.Asset))
+	buf = strconv.AppendUint(buf, cfg.Amount, 10)
+	buf = append(buf, '|')
+	buf = append(buf, cfg.Asset...)
+	buf = append(buf, '|')
+	buf = strconv.AppendUint(buf, cfg.PeriodBlocks, 10)
+	buf = append(buf, '|')
+	buf = strconv.AppendUint(buf, cfg.Periods, 10)
+	buf = append(buf, '|')
+	buf = strconv.AppendUint(buf, cfg.Start, 10)
+	sdk.StateSetObject(subscriptionKey(subID)+"|c", string(buf))
+}
+
+// loadSubscriptionConfig retrieves the terms of a subscription.
+func loadSubscriptionConfig(subID uint64) subscriptionConfig {
+	ptr := sdk.StateGetObject(subscriptionKey(subID) + "|c")
+	if ptr == nil || *ptr == "" {
+		sdk.Abort(fmt.Sprintf("config for subscription %d not found", subID))
+	}
+	parts := strings.Split(*ptr, "|")
+	if len(parts) != 5 {
+		sdk.Abort("invalid subscription config")
+	}
+	return subscriptionConfig{
+		Amount:       StringToUInt64(&parts[0]),
+		Asset:        parts[1],
+		PeriodBlocks: StringToUInt64(&parts[2]),
+		Periods:      StringToUInt64(&parts[3]),
+		Start:        StringToUInt64(&parts[4]),
+	}
+}
+
+// saveSubscriptionBalance stores the unspent balance (milli) of a subscription.
+func saveSubscriptionBalance(subID uint64, balance uint64) {
+	sdk.StateSetObject(subscriptionKey(subID)+"|b", strconv.FormatUint(balance, 10))
+}
+
+// loadSubscriptionBalance retrieves the unspent balance (milli) of a subscription.
+func loadSubscriptionBalance(subID uint64) uint64 {
+	ptr := sdk.StateGetObject(subscriptionKey(subID) + "|b")
+	if ptr == nil || *ptr == "" {
+		return 0
+	}
+	return StringToUInt64(ptr)
+}
+
+// loadSubscriptionChildren retrieves the escrow IDs created for a subscription.
+func loadSubscriptionChildren(subID uint64) []uint64 {
+	ptr := sdk.StateGetObject(subscriptionKey(subID) + "|ch")
+	if ptr == nil || *ptr == "" {
+		return nil
+	}
+	parts := strings.Split(*ptr, "|")
+	ids := make([]uint64, len(parts))
+	for i := range parts {
+		ids[i] = StringToUInt64(&parts[i])
+	}
+	return ids
+}
+
+// subscriptionStopped reports whether future periods of a subscription were stopped.
+func subscriptionStopped(subID uint64) bool {
+	ptr := sdk.StateGetObject(subscriptionKey(subID) + "|x")
+	return ptr != nil && *ptr != ""
+}
+
+// newSubscriptionID reads the next subscription ID from state; defaults to 0 if unset.
+func newSubscriptionID() uint64 {
+	ptr := sdk.StateGetObject("cnt:s")
+	if ptr == nil || *ptr == "" {
+		return 0
+	}
+	return StringToUInt64(ptr)
+}
+
+// setSubscriptionCount persists the next subscription ID counter.
+func setSubscriptionCount(n uint64) {
+	sdk.StateSetObject("cnt:s", strconv.FormatUint(n, 10))
+}
+
+// =====================
+// Subscription Events
+// =====================
+
+// EmitSubscriptionCreatedEvent emits an event for a newly created subscription.
+func EmitSubscriptionCreatedEvent(subID uint64, fromAddress string, toAddress string, arbAddress string, amount float64, asset string, periodBlocks uint64, periods uint64, txID string) {
+	emitEvent("sc", map[string]string{
+		"id":  strconv.FormatUint(subID, 10),
+		"f":   fromAddress,
+		"t":   toAddress,
+		"arb": arbAddress,
+		"am":  strconv.FormatFloat(amount, 'f', -1, 64),
+		"as":  asset,
+		"pb":  strconv.FormatUint(periodBlocks, 10),
+		"pc":  strconv.FormatUint(periods, 10),
+	}, txID)
+}
+
+// EmitSubscriptionPeriodEvent emits an event for a child escrow created for a period.
+func EmitSubscriptionPeriodEvent(subID uint64, period uint64, escrowID uint64, txID string) {
+	emitEvent("sp", map[string]string{
+		"id": strconv.FormatUint(subID, 10),
+		"p":  strconv.FormatUint(period, 10),
+		"e":  strconv.FormatUint(escrowID, 10),
+	}, txID)
+}
+
+// EmitSubscriptionDepositEvent emits an event for a subscription top-up.
+func EmitSubscriptionDepositEvent(subID uint64, amount float64, txID string) {
+	emitEvent("sd", map[string]string{
+		"id": strconv.FormatUint(subID, 10),
+		"am": strconv.FormatFloat(amount, 'f', -1, 64),
+	}, txID)
+}
+
+// EmitSubscriptionStoppedEvent emits an event for a stopped subscription.
+func EmitSubscriptionStoppedEvent(subID uint64, address string, refunded float64, txID string) {
+	emitEvent("ss", map[string]string{
+		"id": strconv.FormatUint(subID, 10),
+		"a":  address,
+		"am": strconv.FormatFloat(refunded, 'f', -1, 64),
+	}, txID)
+}
@@ -0,0 +1,330 @@
+package main
+
+import (
+	"okinoko_escrow/sdk"
+	"strconv"
+	"strings"
+)
+
+// =====================
+// Wagers
+// =====================
+
+// EscrowWager describes the stakes and settlement source of a wager escrow.
+type EscrowWager struct {
+	FromStake float64 `json:"fs"`
+	ToStake   float64 `json:"ts"`
+	Fee       uint64  `json:"fee"`
+	Oracle    string  `json:"or,omitempty"`
+}
+
+// CreateWagerArgs are arguments to create a new wager between creator and opponent.
+type CreateWagerArgs struct {
+	CreateEscrowArgs
+	OpponentStake uint64
+	Fee           uint64
+	OracleID      string
+	OracleKey     string
+}
+
+// wagerTerms holds the stored stakes (milli), fee (bps) and oracle of a wager.
+type wagerTerms struct {
+	FromStake uint64
+	ToStake   uint64
+	Fee       uint64
+	OracleID  string
+	OracleKey string
+}
+
+// CsvToCreateWagerArgs parses a pipe-delimited string into CreateWagerArgs
+// (Name|Opponent|Arbitrator|OpponentStake|FeeBps[|oracle=contractId:key]).
+// An opponent stake of 0 requires the same stake as the creator.
+func CsvToCreateWagerArgs(csv *string) CreateWagerArgs {
+	if csv == nil || *csv == "" {
+		sdk.Abort("input CSV is nil or empty")
+	}
+
+	parts := strings.Split(*csv, "|")
+	if len(parts) != 5 && len(parts) != 6 {
+		sdk.Abort("invalid CSV format: expected Name|Opponent|Arbitrator|OpponentStake|FeeBps[|oracle=contractId:key]")
+	}
+
+	stake, err := strconv.ParseUint(parts[3], 10, 64)
+	if err != nil {
+		sdk.Abort("invalid opponent stake: must be a milli amount")
+	}
+	fee, err := strconv.ParseUint(parts[4], 10, 64)
+	if err != nil || fee > maxBasisPoints {
+		sdk.Abort("invalid fee: must be 0-10000 basis points")
+	}
+
+	args := CreateWagerArgs{
+		CreateEscrowArgs: CreateEscrowArgs{
+			Name:       parts[0],
+			To:         parts[1],
+			Arbitrator: parts[2],
+		},
+		OpponentStake: stake,
+		Fee:           fee,
+	}
+	if len(parts) == 6 {
+		value, found := strings.CutPrefix(parts[5], "oracle=")
+		if !found {
+			sdk.Abort("unknown option: expected oracle=contractId:key")
+		}
+		args.OracleID, args.OracleKey, found = strings.Cut(value, ":")
+		if !found || args.OracleID == "" || args.OracleKey == "" {
+			sdk.Abort("invalid oracle: expected contractId:key")
+		}
+	}
+	return args
+}
+
+// CreateWager creates a wager staked by the creator's transfer.allow intent.
+// The wager activates once the opponent staked.
+//
+//go:wasmexport e_create_wager
+func CreateWager(payload *string) *string {
+	input := CsvToCreateWagerArgs(payload)
+	creator := actingAddress()
+
+	input.Validate(*creator)
+	if input.To == *creator {
+		sdk.Abort("opponent must differ from creator")
+	}
+
+	ta := GetFirstTransferAllow(sdk.GetEnv().Intents)
+	if ta == nil {
+		sdk.Abort("intent needed")
+	}
+	opponentStake := input.OpponentStake
+	if opponentStake == 0 {
+		opponentStake = ta.LimitMilli
+	}
+	drawFunds(ta.LimitMilli, ta.Token.String())
+
+	// The reward holds the pot and grows with the opponent's stake.
+	escrowID := newEscrowID()
+	initEscrow(escrowID, input.Name, *creator, input.To, input.Arbitrator, ta.LimitMilli, ta.Token.String())
+	saveEscrowKind(escrowID, KindWager)
+	saveEscrowStatus(escrowID, StatusFunding)
+	saveWagerTerms(escrowID, wagerTerms{
+		FromStake: ta.LimitMilli,
+		ToStake:   opponentStake,
+		Fee:       input.Fee,
+		OracleID:  input.OracleID,
+		OracleKey: input.OracleKey,
+	})
+
+	txID := sdk.GetEnvKey("tx.id")
+	EmitEscrowCreatedEvent(escrowID, *creator, input.To, input.Arbitrator, float64(ta.LimitMilli)/1000, ta.Token.String(), map[string]string{
+		"k":   KindWager,
+		"ts":  strconv.FormatFloat(float64(opponentStake)/1000, 'f', -1, 64),
+		"fee": strconv.FormatUint(input.Fee, 10),
+	}, *txID)
+
+	result := strconv.FormatUint(escrowID, 10)
+	return &result
+}
+
+// StakeWager adds the opponent's stake from a transfer.allow intent and activates the wager.
+//
+//go:wasmexport e_stake
+func StakeWager(id *string) *string {
+	escrowID := StringToUInt64(id)
+	if loadKind(escrowID) != KindWager {
+		sdk.Abort("escrow is not a wager")
+	}
+	if loadStatus(escrowID) != StatusFunding {
+		sdk.Abort("wager already staked")
+	}
+	if closed, _ := loadEscrowOutcome(escrowID, loadDecisions(escrowID)); closed {
+		sdk.Abort("escrow already closed")
+	}
+
+	sender := actingAddress()
+	roles := loadRoles(escrowID)
+	if *sender != roles[1] {
+		sdk.Abort("only the opponent can stake")
+	}
+
+	terms := loadWagerTerms(escrowID)
+	pot, asset := loadReward(escrowID)
+	ta := GetFirstTransferAllow(sdk.GetEnv().Intents)
+	if ta == nil {
+		sdk.Abort("intent needed")
+	}
+	if ta.Token.String() != asset {
+		sdk.Abort("intent asset does not match wager asset")
+	}
+	if ta.LimitMilli < terms.ToStake {
+		sdk.Abort("intent below required stake")
+	}
+
+	drawFunds(terms.ToStake, ta.Token.String())
+	pot += terms.ToStake
+	saveEscrowReward(escrowID, pot, asset)
+	saveEscrowStatus(escrowID, StatusActive)
+
+	txID := sdk.GetEnvKey("tx.id")
+	EmitContributionEvent(escrowID, *sender, float64(terms.ToStake)/1000, *txID)
+	EmitEscrowFundedEvent(escrowID, float64(pot)/1000, *txID)
+	return nil
+}
+
+// DeclareWager lets the arbitrator declare the result (EscrowID|Result).
+// Result is f (creator wins), t (opponent wins), tie or void.
+//
+//go:wasmexport e_declare
+func DeclareWager(payload *string) *string {
+	if payload == nil || *payload == "" {
+		sdk.Abort("input CSV is nil or empty")
+	}
+	idStr, result, found := strings.Cut(*payload, "|")
+	if !found {
+		sdk.Abort("invalid CSV format: expected EscrowID|Result")
+	}
+	escrowID := StringToUInt64(&idStr)
+	requireActiveWager(escrowID)
+
+	sender := actingAddress()
+	roles := loadRoles(escrowID)
+	if *sender != roles[2] {
+		sdk.Abort("only the arbitrator can declare the result")
+	}
+
+	txID := sdk.GetEnvKey("tx.id")
+	settleWager(escrowID, parseWagerResult(result, roles), "arb", *txID)
+	return nil
+}
+
+// SettleWager settles a wager from the result stored in its oracle contract; callable by anyone.
+//
+//go:wasmexport e_settle
+func SettleWager(id *string) *string {
+	escrowID := StringToUInt64(id)
+	requireActiveWager(escrowID)
+
+	terms := loadWagerTerms(escrowID)
+	if terms.OracleID == "" {
+		sdk.Abort("wager has no oracle")
+	}
+	value := sdk.ContractStateGet(terms.OracleID, terms.OracleKey)
+	if value == nil || *value == "" {
+		sdk.Abort("oracle result not available")
+	}
+
+	txID := sdk.GetEnvKey("tx.id")
+	settleWager(escrowID, parseWagerResult(*value, loadRoles(escrowID)), "or", *txID)
+	return nil
+}
+
+// =====================
+// Wager Helpers
+// =====================
+
+// requireActiveWager aborts unless the escrow is a staked, open wager.
+func requireActiveWager(escrowID uint64) {
+	if loadKind(escrowID) != KindWager {
+		sdk.Abort("escrow is not a wager")
+	}
+	if loadStatus(escrowID) != StatusActive {
+		sdk.Abort("wager not staked")
+	}
+	if closed, _ := loadEscrowOutcome(escrowID, loadDecisions(escrowID)); closed {
+		sdk.Abort("escrow already closed")
+	}
+}
+
+// parseWagerResult maps a result label or winner address to an outcome.
+// The creator winning maps to refund, the opponent winning to release.
+func parseWagerResult(result string, roles []string) uint8 {
+	switch strings.TrimSpace(result) {
+	case "f", roles[0]:
+		return DecisionRefund
+	case "t", roles[1]:
+		return DecisionRelease
+	case "tie", "void":
+		return OutcomeVoid
+	default:
+		sdk.Abort("invalid result: must be f/t/tie/void")
+	}
+	return OutcomeVoid
+}
+
+// settleWager pays the pot for the outcome, persists it and emits a close event.
+// The winner receives the pot minus the arbitrator fee; void results refund both stakes.
+func settleWager(escrowID uint64, outcome uint8, source string, txID string) {
+	terms := loadWagerTerms(escrowID)
+	pot, asset := loadReward(escrowID)
+	roles := loadRoles(escrowID)
+
+	details := map[string]string{"src": source}
+	if outcome == OutcomeVoid {
+		payParty(escrowID, 0, roles[0], terms.FromStake, asset)
+		payParty(escrowID, 1, roles[1], terms.ToStake, asset)
+	} else {
+		winner := uint8(0)
+		if outcome == DecisionRelease {
+			winner = 1
+		}
+		fee := mulDiv(pot, terms.Fee, maxBasisPoints)
+		if fee > 0 {
+			payParty(escrowID, 2, roles[2], fee, asset)
+		}
+		payParty(escrowID, winner, roles[winner], pot-fee, asset)
+		details["w"] = roles[winner]
+		details["fee"] = strconv.FormatFloat(float64(fee)/1000, 'f', -1, 64)
+	}
+
+	closeEscrow(escrowID, outcome, pot, details, txID)
+}
+
+// saveWagerTerms stores fromStake|toStake|fee|oracleId|oracleKey of a wager.
+func saveWagerTerms(escrowID uint64, terms wagerTerms) {
+	key := strconv.FormatUint(escrowID, 10) + "|w"
+	buf := make([]byte, 0, 64+len(terms.OracleID)+len(terms.OracleKey))
+	buf = strconv.AppendUint(buf, terms.FromStake, 10)
+	buf = append(buf, '|')
+	buf = strconv.AppendUint(buf, terms.ToStake, 10)
+	buf = append(buf, '|')
+	buf = strconv.AppendUint(buf, terms.Fee, 10)
+	buf = append(buf, '|')
+	buf = append(buf, terms.OracleID...)
+	buf = append(buf, '|')
+	buf = append(buf, terms.OracleKey...)
+	sdk.StateSetObject(key, string(buf))
+}
+
+// loadWagerTerms retrieves the stakes, fee and oracle of a wager.
+func loadWagerTerms(escrowID uint64) wagerTerms {
+	key := strconv.FormatUint(escrowID, 10) + "|w"
+	ptr := sdk.StateGetObject(key)
+	if ptr == nil || *ptr == "" {
+		sdk.Abort("wager terms not found")
+	}
+	parts := strings.Split(*ptr, "|")
+	if len(parts) != 5 {
+		sdk.Abort("invalid wager terms")
+	}
+	return wagerTerms{
+		FromStake: StringToUInt64(&parts[0]),
+		ToStake:   StringToUInt64(&parts[1]),
+		Fee:       StringToUInt64(&parts[2]),
+		OracleID:  parts[3],
+		OracleKey: parts[4],
+	}
+}
+
+// fillWager adds stakes, fee and oracle to an escrow view.
+func fillWager(escrow *Escrow) {
+	terms := loadWagerTerms(escrow.ID)
+	escrow.Wager = &EscrowWager{
+		FromStake: float64(terms.FromStake) / 1000,
+		ToStake:   float64(terms.ToStake) / 1000,
+		Fee:       terms.Fee,
+	}
+	if terms.OracleID != "" {
+		escrow.Wager.Oracle = terms.OracleID + ":" + terms.OracleKey
+	}
+}
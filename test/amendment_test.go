@@ -0,0 +1,96 @@
+package contract_test
+
+import (
+	"testing"
+	"vsc-node/modules/db/vsc/contracts"
+	ledgerDb "vsc-node/modules/db/vsc/ledger"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// an amount reduction applies once both sides confirmed and refunds the difference
+func TestAmendmentReduceAmount(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create",
+		[]byte("smaller job|hive:receiver|hive:arbitrator"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_propose_amendment", []byte("0|am=600|n=even smaller job"), nil, "hive:receiver", true, uint(100_000_000))
+	CallContract(t, ct, "e_confirm_amendment", []byte("0|0"), nil, "hive:sender", true, uint(100_000_000))
+
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:receiver", true, uint(100_000_000))
+	assert.Equal(t, int64(600), ct.GetBalance("hive:receiver", ledgerDb.AssetHive))
+}
+
+// the arbitrator cannot confirm an amendment that leaves it in place
+func TestAmendmentConfirmByArbitrator(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create",
+		[]byte("smaller job|hive:receiver|hive:arbitrator"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_propose_amendment", []byte("0|n=renamed job"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_confirm_amendment", []byte("0|0"), nil, "hive:arbitrator", false, uint(100_000_000))
+}
+
+// replacing the arbitrator needs the confirmation of the outgoing arbitrator
+func TestAmendmentReplaceArbitrator(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create",
+		[]byte("smaller job|hive:receiver|hive:arbitrator"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_propose_amendment", []byte("0|arb=hive:arbitrator2"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_confirm_amendment", []byte("0|0"), nil, "hive:receiver", true, uint(100_000_000))
+
+	// sender and receiver alone cannot replace it
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:arbitrator2", false, uint(100_000_000))
+	CallContract(t, ct, "e_confirm_amendment", []byte("0|0"), nil, "hive:arbitrator", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:arbitrator2", true, uint(100_000_000))
+	assert.Equal(t, int64(1000), ct.GetBalance("hive:receiver", ledgerDb.AssetHive))
+}
+
+// the creator of a co-funded escrow cannot change its terms
+func TestAmendmentCofunded(t *testing.T) {
+	ct := SetupContractTest()
+	ct.Deposit("hive:funder", 1000, ledgerDb.AssetHive)
+
+	CallContract(t, ct, "e_create",
+		[]byte("shared job|hive:receiver|hive:arbitrator|cof=1"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_cofund", []byte("0"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:funder", true, uint(100_000_000))
+	CallContract(t, ct, "e_propose_amendment", []byte("0|arb=hive:arbitrator2"), nil, "hive:sender", false, uint(100_000_000))
+}
+
+// only deposits and metered escrows have a deadline to amend
+func TestAmendmentDeadline(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create",
+		[]byte("standard job|hive:receiver|hive:arbitrator"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "0.500", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_propose_amendment", []byte("0|dl=2000000"), nil, "hive:sender", false, uint(100_000_000))
+
+	CallContract(t, ct, "e_create",
+		[]byte("deliveries|hive:receiver|hive:arbitrator|unit=100:5:1000000"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "0.500", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_propose_amendment", []byte("1|dl=2000000"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_confirm_amendment", []byte("1|0"), nil, "hive:receiver", true, uint(100_000_000))
+}
+
+// a reduction must still cover the unconfirmed units of a metered escrow
+func TestAmendmentAmountBelowUnits(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create",
+		[]byte("deliveries|hive:receiver|hive:arbitrator|unit=100:5:1000000"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_confirm_units", []byte("0|2"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_propose_amendment", []byte("0|am=299"), nil, "hive:sender", false, uint(100_000_000))
+	CallContract(t, ct, "e_propose_amendment", []byte("0|am=300"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_confirm_amendment", []byte("0|0"), nil, "hive:receiver", true, uint(100_000_000))
+	assert.Equal(t, int64(500), ct.GetBalance("hive:sender", ledgerDb.AssetHive))
+}
@@ -0,0 +1,79 @@
+package contract_test
+
+import (
+	"testing"
+	"vsc-node/modules/db/vsc/contracts"
+	ledgerDb "vsc-node/modules/db/vsc/ledger"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// the new receiver takes over after sender and arbitrator consented
+func TestAssignWithConsent(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create",
+		[]byte("handover|hive:receiver|hive:arbitrator"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_assign", []byte("0|hive:colleague"), nil, "hive:receiver", true, uint(100_000_000))
+	CallContract(t, ct, "e_assign_respond", []byte("0|y"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_assign_respond", []byte("0|y"), nil, "hive:arbitrator", true, uint(100_000_000))
+
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:receiver", false, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:colleague", true, uint(100_000_000))
+	assert.Equal(t, int64(1000), ct.GetBalance("hive:colleague", ledgerDb.AssetHive))
+}
+
+// an objection cancels the assignment
+func TestAssignObjected(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create",
+		[]byte("handover|hive:receiver|hive:arbitrator|asg=o:10"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_assign", []byte("0|hive:colleague"), nil, "hive:receiver", true, uint(100_000_000))
+	CallContract(t, ct, "e_assign_respond", []byte("0|n"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_assign_complete", []byte("0"), nil, "hive:receiver", false, uint(100_000_000))
+}
+
+// an amendment confirmed by the old receiver does not survive the assignment
+func TestAssignCancelsAmendment(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create",
+		[]byte("handover|hive:receiver|hive:arbitrator"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_propose_amendment", []byte("0|am=100"), nil, "hive:receiver", true, uint(100_000_000))
+	CallContract(t, ct, "e_assign", []byte("0|hive:colleague"), nil, "hive:receiver", true, uint(100_000_000))
+	CallContract(t, ct, "e_assign_respond", []byte("0|y"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_assign_respond", []byte("0|y"), nil, "hive:arbitrator", true, uint(100_000_000))
+	CallContract(t, ct, "e_confirm_amendment", []byte("0|0"), nil, "hive:sender", false, uint(100_000_000))
+}
+
+// the new receiver must not be the refund address or a backer, and bonded escrows keep their receiver
+func TestAssignNeutrality(t *testing.T) {
+	ct := SetupContractTest()
+	ct.Deposit("hive:backer", 1000, ledgerDb.AssetHive)
+	ct.Deposit("hive:receiver", 1000, ledgerDb.AssetHive)
+
+	CallContract(t, ct, "e_create",
+		[]byte("sponsored|hive:receiver|hive:arbitrator|from=hive:manager"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "0.300", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_assign", []byte("0|hive:sender"), nil, "hive:receiver", false, uint(100_000_000))
+
+	CallContract(t, ct, "e_create",
+		[]byte("cofunded|hive:receiver|hive:arbitrator|cof=1"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "0.300", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_cofund", []byte("1"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "0.300", "token": "hive"}}}, "hive:backer", true, uint(100_000_000))
+	CallContract(t, ct, "e_assign", []byte("1|hive:backer"), nil, "hive:receiver", false, uint(100_000_000))
+
+	CallContract(t, ct, "e_create",
+		[]byte("bonded|hive:receiver|hive:arbitrator|bond=100"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "0.300", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_bond", []byte("2"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "0.100", "token": "hive"}}}, "hive:receiver", true, uint(100_000_000))
+	CallContract(t, ct, "e_assign", []byte("2|hive:colleague"), nil, "hive:receiver", false, uint(100_000_000))
+	CallContract(t, ct, "e_propose_amendment", []byte("2|to=hive:colleague"), nil, "hive:sender", false, uint(100_000_000))
+}
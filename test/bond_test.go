@@ -0,0 +1,66 @@
+package contract_test
+
+import (
+	"testing"
+	"vsc-node/modules/db/vsc/contracts"
+	ledgerDb "vsc-node/modules/db/vsc/ledger"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// escrow with bond stays inactive until the receiver posts the bond; RELEASE returns the bond
+func TestBondPostRelease(t *testing.T) {
+	ct := SetupContractTest()
+	ct.Deposit("hive:receiver", 500, ledgerDb.AssetHive)
+
+	CallContract(t, ct, "e_create",
+		[]byte("escrow name|hive:receiver|hive:arbitrator|bond=500:5000"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:sender", false, uint(100_000_000))
+
+	CallContract(t, ct, "e_bond", []byte("0"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "0.500", "token": "hive"}}}, "hive:receiver", true, uint(100_000_000))
+	assert.Equal(t, int64(0), ct.GetBalance("hive:receiver", ledgerDb.AssetHive))
+
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:receiver", true, uint(100_000_000))
+	assert.Equal(t, int64(1500), ct.GetBalance("hive:receiver", ledgerDb.AssetHive))
+}
+
+// REFUND pays the configured bond share to the sender
+func TestBondPostRefund(t *testing.T) {
+	ct := SetupContractTest()
+	ct.Deposit("hive:receiver", 500, ledgerDb.AssetHive)
+
+	CallContract(t, ct, "e_create",
+		[]byte("escrow name|hive:receiver|hive:arbitrator|bond=500:5000"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_bond", []byte("0"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "0.500", "token": "hive"}}}, "hive:receiver", true, uint(100_000_000))
+
+	CallContract(t, ct, "e_decide", []byte("0|f"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|f"), nil, "hive:arbitrator", true, uint(100_000_000))
+	assert.Equal(t, int64(1250), ct.GetBalance("hive:sender", ledgerDb.AssetHive))
+	assert.Equal(t, int64(250), ct.GetBalance("hive:receiver", ledgerDb.AssetHive))
+}
+
+// sender cancels while the bond is still missing
+func TestBondCancelBeforePosting(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create",
+		[]byte("escrow name|hive:receiver|hive:arbitrator|bond=500"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_cancel", []byte("0"), nil, "hive:receiver", false, uint(100_000_000))
+	CallContract(t, ct, "e_cancel", []byte("0"), nil, "hive:sender", true, uint(100_000_000))
+	assert.Equal(t, int64(1000), ct.GetBalance("hive:sender", ledgerDb.AssetHive))
+}
+
+// unknown creation option
+func TestEscrowCreateUnknownOption(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create",
+		[]byte("escrow name|hive:receiver|hive:arbitrator|foo=1"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", false, uint(100_000_000))
+}
@@ -0,0 +1,98 @@
+package contract_test
+
+import (
+	"testing"
+	"vsc-node/modules/db/vsc/contracts"
+	ledgerDb "vsc-node/modules/db/vsc/ledger"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// a failing callback with the log policy never blocks the close; delivery fails separately
+func TestCallbackLogPolicy(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create",
+		[]byte("shop order|hive:receiver|hive:arbitrator|cb="+HelperID+":h_fail"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:receiver", true, uint(100_000_000))
+	CallContract(t, ct, "e_notify", []byte("0"), nil, "hive:someone", false, uint(100_000_000))
+
+	assert.Equal(t, int64(1000), ct.GetBalance("hive:receiver", ledgerDb.AssetHive))
+}
+
+// a working callback with the log policy is delivered with e_notify
+func TestCallbackLogDelivered(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create",
+		[]byte("shop order|hive:receiver|hive:arbitrator|cb="+HelperID+":h_ok"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_notify", []byte("0"), nil, "hive:someone", false, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:receiver", true, uint(100_000_000))
+	CallContract(t, ct, "e_notify", []byte("0"), nil, "hive:someone", true, uint(100_000_000))
+	CallContract(t, ct, "e_notify", []byte("0"), nil, "hive:someone", false, uint(100_000_000))
+}
+
+// a failing callback with the consented revert policy keeps the escrow open
+func TestCallbackRevertPolicy(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create",
+		[]byte("shop order|hive:receiver|hive:arbitrator|cb="+HelperID+":h_fail:r"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_callback_consent", []byte("0"), nil, "hive:sender", false, uint(100_000_000))
+	CallContract(t, ct, "e_callback_consent", []byte("0"), nil, "hive:receiver", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:receiver", false, uint(100_000_000))
+
+	assert.Equal(t, int64(0), ct.GetBalance("hive:receiver", ledgerDb.AssetHive))
+}
+
+// without the receiver's consent a revert callback is queued and cannot block the release
+func TestCallbackRevertWithoutConsent(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create",
+		[]byte("shop order|hive:receiver|hive:arbitrator|cb="+HelperID+":h_fail:r"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:receiver", true, uint(100_000_000))
+	CallContract(t, ct, "e_notify", []byte("0"), nil, "hive:someone", false, uint(100_000_000))
+
+	assert.Equal(t, int64(1000), ct.GetBalance("hive:receiver", ledgerDb.AssetHive))
+}
+
+// a new receiver has to consent again
+func TestCallbackConsentAfterAssignment(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create",
+		[]byte("shop order|hive:receiver|hive:arbitrator|cb="+HelperID+":h_fail:r"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_callback_consent", []byte("0"), nil, "hive:receiver", true, uint(100_000_000))
+	CallContract(t, ct, "e_assign", []byte("0|hive:colleague"), nil, "hive:receiver", true, uint(100_000_000))
+	CallContract(t, ct, "e_assign_respond", []byte("0|y"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_assign_respond", []byte("0|y"), nil, "hive:arbitrator", true, uint(100_000_000))
+
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:colleague", true, uint(100_000_000))
+	assert.Equal(t, int64(1000), ct.GetBalance("hive:colleague", ledgerDb.AssetHive))
+}
+
+// a consented callback is delivered within the close that follows a partial release
+func TestCallbackAfterPartialRelease(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create",
+		[]byte("shop order|hive:receiver|hive:arbitrator|cb="+HelperID+":h_ok:r"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_callback_consent", []byte("0"), nil, "hive:receiver", true, uint(100_000_000))
+	CallContract(t, ct, "e_release_partial", []byte("0|400"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:receiver", true, uint(100_000_000))
+	CallContract(t, ct, "e_notify", []byte("0"), nil, "hive:someone", false, uint(100_000_000))
+	assert.Equal(t, int64(1000), ct.GetBalance("hive:receiver", ledgerDb.AssetHive))
+}
@@ -0,0 +1,46 @@
+package contract_test
+
+import (
+	"testing"
+	"vsc-node/modules/db/vsc/contracts"
+	ledgerDb "vsc-node/modules/db/vsc/ledger"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// REFUND needs a majority of the funded amount and pays funders back pro-rata
+func TestCofundWeightedRefund(t *testing.T) {
+	ct := SetupContractTest()
+	ct.Deposit("hive:funder1", 2000, ledgerDb.AssetHive)
+	ct.Deposit("hive:funder2", 1000, ledgerDb.AssetHive)
+
+	CallContract(t, ct, "e_create",
+		[]byte("shared job|hive:receiver|hive:arbitrator|cof=1"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_cofund", []byte("0"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "2.000", "token": "hive"}}}, "hive:funder1", true, uint(100_000_000))
+	CallContract(t, ct, "e_cofund", []byte("0"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:funder2", true, uint(100_000_000))
+
+	// sender + funder2 hold only half of the funded amount
+	CallContract(t, ct, "e_decide", []byte("0|f"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|f"), nil, "hive:funder2", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|f"), nil, "hive:receiver", true, uint(100_000_000))
+	assert.Equal(t, int64(0), ct.GetBalance("hive:funder2", ledgerDb.AssetHive))
+
+	CallContract(t, ct, "e_decide", []byte("0|f"), nil, "hive:funder1", true, uint(100_000_000))
+	assert.Equal(t, int64(2000), ct.GetBalance("hive:funder1", ledgerDb.AssetHive))
+	assert.Equal(t, int64(1000), ct.GetBalance("hive:funder2", ledgerDb.AssetHive))
+}
+
+// receiver cannot co-fund
+func TestCofundByReceiver(t *testing.T) {
+	ct := SetupContractTest()
+	ct.Deposit("hive:receiver", 1000, ledgerDb.AssetHive)
+
+	CallContract(t, ct, "e_create",
+		[]byte("shared job|hive:receiver|hive:arbitrator|cof=1"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_cofund", []byte("0"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:receiver", false, uint(100_000_000))
+}
@@ -0,0 +1,38 @@
+package contract_test
+
+import (
+	"testing"
+	"vsc-node/modules/db/vsc/contracts"
+	ledgerDb "vsc-node/modules/db/vsc/ledger"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// a calling contract creates and funds an escrow from its own balance and decides as sender
+func TestContractPartyCreates(t *testing.T) {
+	ct := SetupContractTest()
+	ct.Deposit("contract:"+HelperID, 1000, ledgerDb.AssetHive)
+
+	CallViaHelper(t, ct, "e_create", "dao job|hive:receiver|hive:arbitrator",
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:member", true, uint(100_000_000))
+	assert.Equal(t, int64(0), ct.GetBalance("contract:"+HelperID, ledgerDb.AssetHive))
+
+	// the signing user has no authority over the contract's seat
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:member", false, uint(100_000_000))
+	CallViaHelper(t, ct, "e_decide", "0|r", nil, "hive:member", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:receiver", true, uint(100_000_000))
+	assert.Equal(t, int64(1000), ct.GetBalance("hive:receiver", ledgerDb.AssetHive))
+}
+
+// a contract arbitrator decides through a contract call, the refund goes back to the sender
+func TestContractPartyDecides(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create",
+		[]byte("dao job|hive:receiver|contract:"+HelperID),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|f"), nil, "hive:sender", true, uint(100_000_000))
+	CallViaHelper(t, ct, "e_decide", "0|f", nil, "hive:member", true, uint(100_000_000))
+
+	assert.Equal(t, int64(1000), ct.GetBalance("hive:sender", ledgerDb.AssetHive))
+}
@@ -0,0 +1,86 @@
+package contract_test
+
+import (
+	"testing"
+	"vsc-node/modules/db/vsc/contracts"
+	ledgerDb "vsc-node/modules/db/vsc/ledger"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// create crowdfund without funds and try to vote before goal is met
+func TestCrowdfundDecisionBeforeGoal(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create_cf",
+		[]byte("crowd name|hive:receiver|hive:arbitrator|2000|hive|1000000"),
+		nil, "hive:sender", true, uint(100_000_000))
+
+	CallContract(t, ct, "e_decide",
+		[]byte("0|r"),
+		nil, "hive:sender", false, uint(100_000_000))
+}
+
+// create crowdfund with invalid goal
+func TestCrowdfundCreateZeroGoal(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create_cf",
+		[]byte("crowd name|hive:receiver|hive:arbitrator|0|hive|1000000"),
+		nil, "hive:sender", false, uint(100_000_000))
+}
+
+// backers reach the goal (last contribution capped) and parties agree to RELEASE
+func TestCrowdfundGoalMetRelease(t *testing.T) {
+	ct := SetupContractTest()
+	ct.Deposit("hive:backer", 1000, ledgerDb.AssetHive)
+
+	CallContract(t, ct, "e_create_cf",
+		[]byte("crowd name|hive:receiver|hive:arbitrator|1500|hive|1000000"),
+		nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_contribute", []byte("0"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_contribute", []byte("0"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:backer", true, uint(100_000_000))
+	assert.Equal(t, int64(500), ct.GetBalance("hive:backer", ledgerDb.AssetHive))
+
+	// goal reached, no more contributions
+	CallContract(t, ct, "e_contribute", []byte("0"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "0.500", "token": "hive"}}}, "hive:backer", false, uint(100_000_000))
+
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:receiver", true, uint(100_000_000))
+	assert.Equal(t, int64(1500), ct.GetBalance("hive:receiver", ledgerDb.AssetHive))
+	CallContract(t, ct, "e_get", []byte("0"), nil, "hive:sender", true, uint(100_000_000))
+}
+
+// backers reach the goal and parties agree to REFUND
+func TestCrowdfundGoalMetRefund(t *testing.T) {
+	ct := SetupContractTest()
+	ct.Deposit("hive:backer", 1000, ledgerDb.AssetHive)
+
+	CallContract(t, ct, "e_create_cf",
+		[]byte("crowd name|hive:receiver|hive:arbitrator|1500|hive|1000000"),
+		nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_contribute", []byte("0"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "0.500", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_contribute", []byte("0"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:backer", true, uint(100_000_000))
+
+	CallContract(t, ct, "e_decide", []byte("0|f"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|f"), nil, "hive:arbitrator", true, uint(100_000_000))
+	assert.Equal(t, int64(1000), ct.GetBalance("hive:sender", ledgerDb.AssetHive))
+	assert.Equal(t, int64(1000), ct.GetBalance("hive:backer", ledgerDb.AssetHive))
+}
+
+// withdrawal is not possible while the crowdfund is still collecting
+func TestCrowdfundWithdrawBeforeDeadline(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create_cf",
+		[]byte("crowd name|hive:receiver|hive:arbitrator|2000|hive|1000000"),
+		nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_contribute", []byte("0"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_withdraw", []byte("0"), nil, "hive:sender", false, uint(100_000_000))
+}
@@ -0,0 +1,67 @@
+package contract_test
+
+import (
+	"testing"
+	"vsc-node/modules/db/vsc/contracts"
+	ledgerDb "vsc-node/modules/db/vsc/ledger"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// the dependent escrow can be decided once its dependency released
+func TestDependencyReleased(t *testing.T) {
+	ct := SetupContractTest()
+	ct.Deposit("hive:receiver", 500, ledgerDb.AssetHive)
+
+	CallContract(t, ct, "e_create",
+		[]byte("main job|hive:receiver|hive:arbitrator"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_create",
+		[]byte("sub job|hive:subcontractor|hive:arbitrator|after=0:r"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "0.500", "token": "hive"}}}, "hive:receiver", true, uint(100_000_000))
+
+	// blocked until the main escrow released
+	CallContract(t, ct, "e_decide", []byte("1|r"), nil, "hive:receiver", false, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:receiver", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("1|r"), nil, "hive:receiver", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("1|r"), nil, "hive:subcontractor", true, uint(100_000_000))
+
+	assert.Equal(t, int64(500), ct.GetBalance("hive:subcontractor", ledgerDb.AssetHive))
+}
+
+// the dependent escrow can be refunded by anyone once its dependency closed with the other outcome
+func TestDependencyRefunded(t *testing.T) {
+	ct := SetupContractTest()
+	ct.Deposit("hive:receiver", 500, ledgerDb.AssetHive)
+
+	CallContract(t, ct, "e_create",
+		[]byte("main job|hive:receiver|hive:arbitrator"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_create",
+		[]byte("sub job|hive:subcontractor|hive:arbitrator|after=0:r"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "0.500", "token": "hive"}}}, "hive:receiver", true, uint(100_000_000))
+	CallContract(t, ct, "e_resolve", []byte("1"), nil, "hive:someone", false, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|f"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|f"), nil, "hive:receiver", true, uint(100_000_000))
+	CallContract(t, ct, "e_resolve", []byte("1"), nil, "hive:someone", true, uint(100_000_000))
+
+	assert.Equal(t, int64(500), ct.GetBalance("hive:receiver", ledgerDb.AssetHive))
+}
+
+// refunds do not wait for the dependency
+func TestDependencyRefundBeforeMet(t *testing.T) {
+	ct := SetupContractTest()
+	ct.Deposit("hive:receiver", 500, ledgerDb.AssetHive)
+
+	CallContract(t, ct, "e_create",
+		[]byte("main job|hive:receiver|hive:arbitrator"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_create",
+		[]byte("sub job|hive:subcontractor|hive:arbitrator|after=0:r"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "0.500", "token": "hive"}}}, "hive:receiver", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("1|f"), nil, "hive:receiver", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("1|f"), nil, "hive:arbitrator", true, uint(100_000_000))
+
+	assert.Equal(t, int64(500), ct.GetBalance("hive:receiver", ledgerDb.AssetHive))
+}
@@ -0,0 +1,59 @@
+package contract_test
+
+import (
+	"testing"
+	"vsc-node/modules/db/vsc/contracts"
+	ledgerDb "vsc-node/modules/db/vsc/ledger"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// the holder receives accepted and arbitrated deductions, the rest returns to the payer
+func TestDepositDeductions(t *testing.T) {
+	ct := SetupContractTest()
+	ct.Deposit("hive:tenant", 1000, ledgerDb.AssetHive)
+
+	CallContract(t, ct, "e_create",
+		[]byte("flat deposit|hive:landlord|hive:arbitrator|dep=1000000"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:tenant", true, uint(100_000_000))
+	CallContract(t, ct, "e_claim", []byte("0|100|damage|abc123"), nil, "hive:landlord", true, uint(100_000_000))
+	CallContract(t, ct, "e_claim", []byte("0|300|cleaning|def456"), nil, "hive:landlord", true, uint(100_000_000))
+	CallContract(t, ct, "e_respond", []byte("0|0|a"), nil, "hive:tenant", true, uint(100_000_000))
+	CallContract(t, ct, "e_respond", []byte("0|1|c"), nil, "hive:tenant", true, uint(100_000_000))
+
+	// contested line must be ruled first
+	CallContract(t, ct, "e_payout", []byte("0"), nil, "hive:landlord", false, uint(100_000_000))
+	CallContract(t, ct, "e_rule", []byte("0|1|150"), nil, "hive:arbitrator", true, uint(100_000_000))
+	CallContract(t, ct, "e_payout", []byte("0"), nil, "hive:landlord", true, uint(100_000_000))
+
+	assert.Equal(t, int64(250), ct.GetBalance("hive:landlord", ledgerDb.AssetHive))
+}
+
+// deductions cannot exceed the deposit
+func TestDepositClaimTooHigh(t *testing.T) {
+	ct := SetupContractTest()
+	ct.Deposit("hive:tenant", 1000, ledgerDb.AssetHive)
+
+	CallContract(t, ct, "e_create",
+		[]byte("flat deposit|hive:landlord|hive:arbitrator|dep=1000000"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:tenant", true, uint(100_000_000))
+	CallContract(t, ct, "e_claim", []byte("0|1001|damage|abc123"), nil, "hive:landlord", false, uint(100_000_000))
+}
+
+// a partial release cannot undercut claimed deductions, so the payout stays covered
+func TestDepositPartialReleaseAfterClaim(t *testing.T) {
+	ct := SetupContractTest()
+	ct.Deposit("hive:tenant", 1000, ledgerDb.AssetHive)
+
+	CallContract(t, ct, "e_create",
+		[]byte("flat deposit|hive:landlord|hive:arbitrator|dep=1000000"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:tenant", true, uint(100_000_000))
+	CallContract(t, ct, "e_claim", []byte("0|800|damage|abc123"), nil, "hive:landlord", true, uint(100_000_000))
+	CallContract(t, ct, "e_respond", []byte("0|0|a"), nil, "hive:tenant", true, uint(100_000_000))
+	CallContract(t, ct, "e_release_partial", []byte("0|500"), nil, "hive:tenant", false, uint(100_000_000))
+	CallContract(t, ct, "e_release_partial", []byte("0|200"), nil, "hive:tenant", true, uint(100_000_000))
+
+	CallContract(t, ct, "e_payout", []byte("0"), nil, "hive:landlord", true, uint(100_000_000))
+	assert.Equal(t, int64(1000), ct.GetBalance("hive:landlord", ledgerDb.AssetHive))
+	assert.Equal(t, int64(0), ct.GetBalance("hive:tenant", ledgerDb.AssetHive))
+}
@@ -0,0 +1,64 @@
+package contract_test
+
+import (
+	"testing"
+	"vsc-node/modules/db/vsc/contracts"
+	ledgerDb "vsc-node/modules/db/vsc/ledger"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// a release forwards the registered share into a child escrow
+func TestForwardRelease(t *testing.T) {
+	ct := SetupContractTest()
+	ct.Deposit("hive:client", 1000, ledgerDb.AssetHive)
+
+	CallContract(t, ct, "e_create",
+		[]byte("agency job|hive:agency|hive:arbitrator"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:client", true, uint(100_000_000))
+	CallContract(t, ct, "e_forward", []byte("0|4000|subcontract|hive:subcontractor|hive:arbitrator2"), nil, "hive:agency", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:client", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:agency", true, uint(100_000_000))
+	assert.Equal(t, int64(600), ct.GetBalance("hive:agency", ledgerDb.AssetHive))
+
+	// the child escrow is a regular escrow from the agency
+	CallContract(t, ct, "e_decide", []byte("1|r"), nil, "hive:agency", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("1|r"), nil, "hive:subcontractor", true, uint(100_000_000))
+	assert.Equal(t, int64(400), ct.GetBalance("hive:subcontractor", ledgerDb.AssetHive))
+}
+
+// only the receiver can forward
+func TestForwardBySender(t *testing.T) {
+	ct := SetupContractTest()
+	ct.Deposit("hive:client", 1000, ledgerDb.AssetHive)
+
+	CallContract(t, ct, "e_create",
+		[]byte("agency job|hive:agency|hive:arbitrator"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:client", true, uint(100_000_000))
+	CallContract(t, ct, "e_forward", []byte("0|4000|subcontract|hive:subcontractor|hive:arbitrator2"), nil, "hive:client", false, uint(100_000_000))
+}
+
+// a partial release forwards its share too and every payout funds its own child escrow
+func TestForwardPartialRelease(t *testing.T) {
+	ct := SetupContractTest()
+	ct.Deposit("hive:client", 1000, ledgerDb.AssetHive)
+
+	CallContract(t, ct, "e_create",
+		[]byte("agency job|hive:agency|hive:arbitrator"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:client", true, uint(100_000_000))
+	CallContract(t, ct, "e_forward", []byte("0|5000|subcontract|hive:subcontractor|hive:arbitrator2"), nil, "hive:agency", true, uint(100_000_000))
+	CallContract(t, ct, "e_release_partial", []byte("0|400"), nil, "hive:client", true, uint(100_000_000))
+	assert.Equal(t, int64(200), ct.GetBalance("hive:agency", ledgerDb.AssetHive))
+
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:client", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:agency", true, uint(100_000_000))
+	assert.Equal(t, int64(500), ct.GetBalance("hive:agency", ledgerDb.AssetHive))
+
+	// the child of the partial release holds 200, the child of the final release 300
+	CallContract(t, ct, "e_decide", []byte("1|r"), nil, "hive:agency", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("1|r"), nil, "hive:subcontractor", true, uint(100_000_000))
+	assert.Equal(t, int64(200), ct.GetBalance("hive:subcontractor", ledgerDb.AssetHive))
+	CallContract(t, ct, "e_decide", []byte("2|r"), nil, "hive:agency", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("2|r"), nil, "hive:subcontractor", true, uint(100_000_000))
+	assert.Equal(t, int64(500), ct.GetBalance("hive:subcontractor", ledgerDb.AssetHive))
+}
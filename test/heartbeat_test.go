@@ -0,0 +1,45 @@
+package contract_test
+
+import (
+	"testing"
+	"vsc-node/modules/db/vsc/contracts"
+	ledgerDb "vsc-node/modules/db/vsc/ledger"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// sender sends a heartbeat and cancels while the heartbeat is current
+func TestHeartbeatCancel(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create",
+		[]byte("inheritance|hive:receiver|hive:arbitrator|hb=100000"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_heartbeat", []byte("0"), nil, "hive:receiver", false, uint(100_000_000))
+	CallContract(t, ct, "e_heartbeat", []byte("0"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_get", []byte("0"), nil, "hive:sender", true, uint(100_000_000))
+
+	CallContract(t, ct, "e_cancel", []byte("0"), nil, "hive:sender", true, uint(100_000_000))
+	assert.Equal(t, int64(1000), ct.GetBalance("hive:sender", ledgerDb.AssetHive))
+}
+
+// trigger is rejected while the heartbeat is current
+func TestHeartbeatTriggerTooEarly(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create",
+		[]byte("inheritance|hive:receiver|hive:arbitrator|hb=100000"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_trigger", []byte("0"), nil, "hive:receiver", false, uint(100_000_000))
+}
+
+// heartbeat actions on a standard escrow
+func TestHeartbeatOnStandardEscrow(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create",
+		[]byte("escrow name|hive:receiver|hive:arbitrator"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_heartbeat", []byte("0"), nil, "hive:sender", false, uint(100_000_000))
+	CallContract(t, ct, "e_cancel", []byte("0"), nil, "hive:sender", false, uint(100_000_000))
+}
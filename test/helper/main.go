@@ -0,0 +1,165 @@
+// Package main is a helper contract for the escrow tests. It acts as a calling contract
+// (DAO, marketplace), as a close callback target that succeeds or fails and as a minimal token.
+// Build it like the escrow contract into test/artifacts/helper.wasm.
+package main
+
+import (
+	"okinoko_escrow/sdk"
+	"strconv"
+	"strings"
+)
+
+// main is required for WASM targets; contract logic is exposed via exported functions.
+func main() {}
+
+// Ok accepts any payload, e.g. as a close callback.
+//
+//go:wasmexport h_ok
+func Ok(payload *string) *string {
+	result := "ok"
+	return &result
+}
+
+// Fail always aborts, e.g. as a failing close callback.
+//
+//go:wasmexport h_fail
+func Fail(payload *string) *string {
+	sdk.Abort("callback rejected")
+	return nil
+}
+
+// Call forwards an action to another contract (ContractId|Action|Payload) with the intents of this call,
+// so the helper contract is msg.caller of the called contract.
+//
+//go:wasmexport h_call
+func Call(payload *string) *string {
+	parts := strings.SplitN(*payload, "|", 3)
+	if len(parts) != 3 {
+		sdk.Abort("invalid CSV format: expected ContractId|Action|Payload")
+	}
+	var opts *sdk.ContractCallOptions
+	if intents := sdk.GetEnv().Intents; len(intents) > 0 {
+		opts = &sdk.ContractCallOptions{Intents: intents}
+	}
+	return sdk.ContractCall(parts[0], parts[1], parts[2], opts)
+}
+
+// =====================
+// Token
+// =====================
+
+// Mint credits tokens to an address (To|Amount); open to anyone since it only serves tests.
+//
+//go:wasmexport mint
+func Mint(payload *string) *string {
+	to, amount := splitTwo(payload)
+	setAmount("b|"+to, getAmount("b|"+to)+parseAmount(amount))
+	return nil
+}
+
+// Approve lets the caller allow a spender to pull tokens (Spender|Amount).
+//
+//go:wasmexport approve
+func Approve(payload *string) *string {
+	spender, amount := splitTwo(payload)
+	setAmount("a|"+*caller()+"|"+spender, parseAmount(amount))
+	return nil
+}
+
+// Allowance returns the amount a spender may pull from an owner (Owner|Spender).
+//
+//go:wasmexport allowance
+func Allowance(payload *string) *string {
+	owner, spender := splitTwo(payload)
+	result := strconv.FormatUint(getAmount("a|"+owner+"|"+spender), 10)
+	return &result
+}
+
+// BalanceOf returns the token balance of an address.
+//
+//go:wasmexport balanceOf
+func BalanceOf(address *string) *string {
+	result := strconv.FormatUint(getAmount("b|"+*address), 10)
+	return &result
+}
+
+// Transfer moves tokens from the caller to an address (To|Amount).
+//
+//go:wasmexport transfer
+func Transfer(payload *string) *string {
+	to, amount := splitTwo(payload)
+	move(*caller(), to, parseAmount(amount))
+	result := "true"
+	return &result
+}
+
+// TransferFrom lets the caller pull approved tokens (From|To|Amount).
+//
+//go:wasmexport transferFrom
+func TransferFrom(payload *string) *string {
+	parts := strings.Split(*payload, "|")
+	if len(parts) != 3 {
+		sdk.Abort("invalid CSV format: expected From|To|Amount")
+	}
+	amount := parseAmount(parts[2])
+	key := "a|" + parts[0] + "|" + *caller()
+	allowed := getAmount(key)
+	if allowed < amount {
+		sdk.Abort("allowance too low")
+	}
+	setAmount(key, allowed-amount)
+	move(parts[0], parts[1], amount)
+	result := "true"
+	return &result
+}
+
+// caller returns the calling contract or the transaction sender.
+func caller() *string {
+	c := sdk.GetEnvKey("msg.caller")
+	if c != nil && sdk.Address(*c).Domain() == sdk.AddressDomainContract {
+		return c
+	}
+	return sdk.GetEnvKey("msg.sender")
+}
+
+// move transfers an amount between two balances.
+func move(from string, to string, amount uint64) {
+	balance := getAmount("b|" + from)
+	if balance < amount {
+		sdk.Abort("balance too low")
+	}
+	setAmount("b|"+from, balance-amount)
+	setAmount("b|"+to, getAmount("b|"+to)+amount)
+}
+
+// splitTwo parses a two-field payload.
+func splitTwo(payload *string) (string, string) {
+	a, b, found := strings.Cut(*payload, "|")
+	if !found {
+		sdk.Abort("invalid CSV format: expected two fields")
+	}
+	return a, b
+}
+
+// parseAmount parses a milli amount.
+func parseAmount(s string) uint64 {
+	v, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		sdk.Abort("invalid amount")
+	}
+	return v
+}
+
+// getAmount reads an amount from state; 0 if unset.
+func getAmount(key string) uint64 {
+	ptr := sdk.StateGetObject(key)
+	if ptr == nil || *ptr == "" {
+		return 0
+	}
+	return parseAmount(*ptr)
+}
+
+// setAmount stores an amount in state.
+func setAmount(key string, v uint64) {
+	sdk.StateSetObject(key, strconv.FormatUint(v, 10))
+}
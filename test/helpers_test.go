@@ -23,11 +23,18 @@ const ownerAddress = "hive:tibfox"
 //go:embed artifacts/main.wasm
 var ContractWasm []byte
 
+// HelperID is the helper contract acting as calling contract and callback target (see test/helper).
+const HelperID = "vsctesthelper"
+
+//go:embed artifacts/helper.wasm
+var HelperWasm []byte
+
 // Setup an Instance of a test
 func SetupContractTest() *test_utils.ContractTest {
 	CleanBadgerDB()
 	ct := test_utils.NewContractTest()
 	ct.RegisterContract(ContractID, ownerAddress, ContractWasm)
+	ct.RegisterContract(HelperID, ownerAddress, HelperWasm)
 	ct.Deposit("hive:sender", 1000, ledgerDb.AssetHive)
 	ct.Deposit("hive:sender", 1000, ledgerDb.AssetHbd)
 	return &ct
@@ -49,6 +56,17 @@ func CleanBadgerDB() {
 
 // CallContract executes a contract action and asserts basic success
 func CallContract(t *testing.T, ct *test_utils.ContractTest, action string, payload json.RawMessage, intents []contracts.Intent, authUser string, expectedResult bool, maxGas uint) (stateEngine.TxResult, uint, map[string][]string) {
+	return CallContractID(t, ct, ContractID, action, payload, intents, authUser, expectedResult, maxGas)
+}
+
+// CallViaHelper lets the helper contract call an escrow action, so the helper is msg.caller of the escrow
+func CallViaHelper(t *testing.T, ct *test_utils.ContractTest, action string, payload string, intents []contracts.Intent, authUser string, expectedResult bool, maxGas uint) (stateEngine.TxResult, uint, map[string][]string) {
+	forward := []byte(ContractID + "|" + action + "|" + payload)
+	return CallContractID(t, ct, HelperID, "h_call", forward, intents, authUser, expectedResult, maxGas)
+}
+
+// CallContractID executes an action of the given contract and asserts basic success
+func CallContractID(t *testing.T, ct *test_utils.ContractTest, contractID string, action string, payload json.RawMessage, intents []contracts.Intent, authUser string, expectedResult bool, maxGas uint) (stateEngine.TxResult, uint, map[string][]string) {
 	fmt.Println(action)
 	result, gasUsed, logs := ct.Call(stateEngine.TxVscCallContract{
 		Caller: authUser,
@@ -62,7 +80,7 @@ func CallContract(t *testing.T, ct *test_utils.ContractTest, action string, payl
 			RequiredAuths:        []string{authUser},
 			RequiredPostingAuths: []string{},
 		},
-		ContractId: ContractID,
+		ContractId: contractID,
 		Action:     action,
 		Payload:    payload,
 		RcLimit:    1000,
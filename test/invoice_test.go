@@ -0,0 +1,52 @@
+package contract_test
+
+import (
+	"testing"
+	"vsc-node/modules/db/vsc/contracts"
+	ledgerDb "vsc-node/modules/db/vsc/ledger"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// receiver issues an invoice, payer funds it and both agree to RELEASE
+func TestInvoicePayRelease(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create_inv",
+		[]byte("invoice name|hive:sender|hive:arbitrator|1000|hive|1000000"),
+		nil, "hive:receiver", true, uint(100_000_000))
+
+	// no decisions before payment
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:receiver", false, uint(100_000_000))
+
+	CallContract(t, ct, "e_pay_invoice", []byte("0"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	assert.Equal(t, int64(0), ct.GetBalance("hive:sender", ledgerDb.AssetHive))
+
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:receiver", true, uint(100_000_000))
+	assert.Equal(t, int64(1000), ct.GetBalance("hive:receiver", ledgerDb.AssetHive))
+}
+
+// only the named payer can pay an invoice
+func TestInvoicePayByOther(t *testing.T) {
+	ct := SetupContractTest()
+	ct.Deposit("hive:other", 1000, ledgerDb.AssetHive)
+
+	CallContract(t, ct, "e_create_inv",
+		[]byte("invoice name|hive:sender|hive:arbitrator|1000|hive|1000000"),
+		nil, "hive:receiver", true, uint(100_000_000))
+	CallContract(t, ct, "e_pay_invoice", []byte("0"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:other", false, uint(100_000_000))
+}
+
+// payment intent must cover the invoiced amount
+func TestInvoicePayBelowAmount(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create_inv",
+		[]byte("invoice name|hive:sender|hive:arbitrator|1000|hive|1000000"),
+		nil, "hive:receiver", true, uint(100_000_000))
+	CallContract(t, ct, "e_pay_invoice", []byte("0"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "0.500", "token": "hive"}}}, "hive:sender", false, uint(100_000_000))
+}
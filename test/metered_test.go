@@ -0,0 +1,46 @@
+package contract_test
+
+import (
+	"testing"
+	"vsc-node/modules/db/vsc/contracts"
+	ledgerDb "vsc-node/modules/db/vsc/ledger"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// confirmed and awarded units are released immediately
+func TestMeteredUnits(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create",
+		[]byte("deliveries|hive:receiver|hive:arbitrator|unit=100:10:1000000"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_confirm_units", []byte("0|3"), nil, "hive:sender", true, uint(100_000_000))
+	assert.Equal(t, int64(300), ct.GetBalance("hive:receiver", ledgerDb.AssetHive))
+
+	CallContract(t, ct, "e_dispute_units", []byte("0|2"), nil, "hive:receiver", true, uint(100_000_000))
+	CallContract(t, ct, "e_rule_units", []byte("0|1"), nil, "hive:arbitrator", true, uint(100_000_000))
+	assert.Equal(t, int64(400), ct.GetBalance("hive:receiver", ledgerDb.AssetHive))
+}
+
+// units cannot exceed the cap
+func TestMeteredUnitsOverCap(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create",
+		[]byte("deliveries|hive:receiver|hive:arbitrator|unit=100:10:1000000"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_confirm_units", []byte("0|11"), nil, "hive:sender", false, uint(100_000_000))
+}
+
+// metered escrows pay out by units, not by decisions
+func TestMeteredDecisionRejected(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create",
+		[]byte("deliveries|hive:receiver|hive:arbitrator|unit=100:10:1000000"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:sender", false, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:arbitrator", false, uint(100_000_000))
+	assert.Equal(t, int64(0), ct.GetBalance("hive:receiver", ledgerDb.AssetHive))
+}
@@ -0,0 +1,69 @@
+package contract_test
+
+import (
+	"testing"
+	"vsc-node/modules/db/vsc/contracts"
+	ledgerDb "vsc-node/modules/db/vsc/ledger"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// an accepted offer closes the escrow with the agreed split
+func TestOfferAccept(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create",
+		[]byte("settled job|hive:receiver|hive:arbitrator"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_offer", []byte("0|7000|1000000"), nil, "hive:sender", true, uint(100_000_000))
+	// offer must match
+	CallContract(t, ct, "e_accept_offer", []byte("0|8000"), nil, "hive:receiver", false, uint(100_000_000))
+	CallContract(t, ct, "e_accept_offer", []byte("0|7000"), nil, "hive:receiver", true, uint(100_000_000))
+
+	assert.Equal(t, int64(700), ct.GetBalance("hive:receiver", ledgerDb.AssetHive))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:sender", false, uint(100_000_000))
+}
+
+// a withdrawn offer cannot be accepted
+func TestOfferWithdrawn(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create",
+		[]byte("settled job|hive:receiver|hive:arbitrator"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_offer", []byte("0|7000|1000000"), nil, "hive:receiver", true, uint(100_000_000))
+	CallContract(t, ct, "e_withdraw_offer", []byte("0"), nil, "hive:receiver", true, uint(100_000_000))
+	CallContract(t, ct, "e_accept_offer", []byte("0|7000"), nil, "hive:sender", false, uint(100_000_000))
+}
+
+// the receiver share of an accepted offer goes through the forward instruction
+func TestOfferForwarded(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create",
+		[]byte("settled job|hive:receiver|hive:arbitrator"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_forward", []byte("0|5000|subcontract|hive:subcontractor|hive:arbitrator2"), nil, "hive:receiver", true, uint(100_000_000))
+	CallContract(t, ct, "e_offer", []byte("0|6000|1000000"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_accept_offer", []byte("0|6000"), nil, "hive:receiver", true, uint(100_000_000))
+	assert.Equal(t, int64(300), ct.GetBalance("hive:receiver", ledgerDb.AssetHive))
+
+	// the child escrow holds the forwarded 300
+	CallContract(t, ct, "e_decide", []byte("1|r"), nil, "hive:receiver", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("1|r"), nil, "hive:subcontractor", true, uint(100_000_000))
+	assert.Equal(t, int64(300), ct.GetBalance("hive:subcontractor", ledgerDb.AssetHive))
+}
+
+// the creator of a co-funded escrow cannot settle the funders' money by offer
+func TestOfferCofunded(t *testing.T) {
+	ct := SetupContractTest()
+	ct.Deposit("hive:funder", 1000, ledgerDb.AssetHive)
+
+	CallContract(t, ct, "e_create",
+		[]byte("shared job|hive:receiver|hive:arbitrator|cof=1"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_cofund", []byte("0"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:funder", true, uint(100_000_000))
+	CallContract(t, ct, "e_offer", []byte("0|9000|1000000"), nil, "hive:receiver", false, uint(100_000_000))
+	CallContract(t, ct, "e_offer", []byte("0|9000|1000000"), nil, "hive:sender", false, uint(100_000_000))
+}
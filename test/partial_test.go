@@ -0,0 +1,59 @@
+package contract_test
+
+import (
+	"testing"
+	"vsc-node/modules/db/vsc/contracts"
+	ledgerDb "vsc-node/modules/db/vsc/ledger"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// partial releases pay the receiver and keep the rest in escrow
+func TestPartialRelease(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create",
+		[]byte("milestones|hive:receiver|hive:arbitrator"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_release_partial", []byte("0|400"), nil, "hive:sender", true, uint(100_000_000))
+	assert.Equal(t, int64(400), ct.GetBalance("hive:receiver", ledgerDb.AssetHive))
+
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:receiver", true, uint(100_000_000))
+	assert.Equal(t, int64(1000), ct.GetBalance("hive:receiver", ledgerDb.AssetHive))
+}
+
+// only the sender can release partially
+func TestPartialReleaseByReceiver(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create",
+		[]byte("milestones|hive:receiver|hive:arbitrator"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_release_partial", []byte("0|400"), nil, "hive:receiver", false, uint(100_000_000))
+}
+
+// the creator of a co-funded escrow cannot release the funders' money alone
+func TestPartialReleaseCofunded(t *testing.T) {
+	ct := SetupContractTest()
+	ct.Deposit("hive:funder", 1000, ledgerDb.AssetHive)
+
+	CallContract(t, ct, "e_create",
+		[]byte("shared job|hive:receiver|hive:arbitrator|cof=1"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "0.200", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_cofund", []byte("0"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:funder", true, uint(100_000_000))
+	CallContract(t, ct, "e_release_partial", []byte("0|600"), nil, "hive:sender", false, uint(100_000_000))
+	assert.Equal(t, int64(0), ct.GetBalance("hive:receiver", ledgerDb.AssetHive))
+}
+
+// a partial release must leave the price of all unconfirmed units
+func TestPartialReleaseMetered(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create",
+		[]byte("deliveries|hive:receiver|hive:arbitrator|unit=100:8:1000000"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_release_partial", []byte("0|201"), nil, "hive:sender", false, uint(100_000_000))
+	CallContract(t, ct, "e_release_partial", []byte("0|200"), nil, "hive:sender", true, uint(100_000_000))
+}
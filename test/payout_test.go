@@ -0,0 +1,36 @@
+package contract_test
+
+import (
+	"testing"
+	"vsc-node/modules/db/vsc/contracts"
+	ledgerDb "vsc-node/modules/db/vsc/ledger"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// a release goes to the receiver's registered payout address
+func TestPayoutToRegisteredAddress(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create",
+		[]byte("payout job|hive:receiver|hive:arbitrator"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_payout_to", []byte("0|t|hive:wallet"), nil, "hive:receiver", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:receiver", true, uint(100_000_000))
+
+	assert.Equal(t, int64(1000), ct.GetBalance("hive:wallet", ledgerDb.AssetHive))
+	assert.Equal(t, int64(0), ct.GetBalance("hive:receiver", ledgerDb.AssetHive))
+}
+
+// withdrawals need a Hive account and only parties can register a payout
+func TestPayoutInvalid(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create",
+		[]byte("payout job|hive:receiver|hive:arbitrator"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_payout_to", []byte("0|w|did:key:z6Mkabc"), nil, "hive:receiver", false, uint(100_000_000))
+	CallContract(t, ct, "e_payout_to", []byte("0|t|hive:wallet"), nil, "hive:someone", false, uint(100_000_000))
+	CallContract(t, ct, "e_payout_to", []byte("0|x|hive:wallet"), nil, "hive:receiver", false, uint(100_000_000))
+}
@@ -0,0 +1,43 @@
+package contract_test
+
+import (
+	"testing"
+	"vsc-node/modules/db/vsc/contracts"
+	ledgerDb "vsc-node/modules/db/vsc/ledger"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// RELEASE is split across receivers by share; the remainder goes to the first receiver
+func TestReceiversSplitRelease(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create",
+		[]byte("team job|hive:receiver|hive:arbitrator|rcv=hive:dev1:3333,hive:dev2:3333,hive:dev3:3334"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:receiver", true, uint(100_000_000))
+
+	assert.Equal(t, int64(334), ct.GetBalance("hive:dev1", ledgerDb.AssetHive))
+	assert.Equal(t, int64(333), ct.GetBalance("hive:dev2", ledgerDb.AssetHive))
+	assert.Equal(t, int64(333), ct.GetBalance("hive:dev3", ledgerDb.AssetHive))
+	assert.Equal(t, int64(0), ct.GetBalance("hive:receiver", ledgerDb.AssetHive))
+}
+
+// shares must sum to 100%
+func TestReceiversInvalidShares(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create",
+		[]byte("team job|hive:receiver|hive:arbitrator|rcv=hive:dev1:5000,hive:dev2:4000"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", false, uint(100_000_000))
+}
+
+// arbitrator cannot be a receiver
+func TestReceiversArbitratorAsReceiver(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create",
+		[]byte("team job|hive:receiver|hive:arbitrator|rcv=hive:dev1:5000,hive:arbitrator:5000"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", false, uint(100_000_000))
+}
@@ -0,0 +1,114 @@
+package contract_test
+
+import (
+	"testing"
+	"vsc-node/modules/db/vsc/contracts"
+	ledgerDb "vsc-node/modules/db/vsc/ledger"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// a rotation approved by both other parties applies immediately
+func TestRotationApproved(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create",
+		[]byte("rotation|hive:receiver|hive:arbitrator"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_rotate", []byte("0|hive:receiver2"), nil, "hive:receiver", true, uint(100_000_000))
+	CallContract(t, ct, "e_rotate_respond", []byte("0|t|y"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_rotate_respond", []byte("0|t|y"), nil, "hive:arbitrator", true, uint(100_000_000))
+
+	// the old address lost its seat
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:receiver", false, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:receiver2", true, uint(100_000_000))
+	assert.Equal(t, int64(1000), ct.GetBalance("hive:receiver2", ledgerDb.AssetHive))
+}
+
+// an unopposed rotation cannot complete before the delay
+func TestRotationDelay(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create",
+		[]byte("rotation|hive:receiver|hive:arbitrator"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_rotate", []byte("0|hive:sender2"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_rotate_complete", []byte("0|f"), nil, "hive:sender", false, uint(100_000_000))
+}
+
+// other parties can propose a rotation for a lost seat but a pending one cannot be replaced
+func TestRotationForOtherSeat(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create",
+		[]byte("rotation|hive:receiver|hive:arbitrator"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_rotate", []byte("0|t|hive:receiver2"), nil, "hive:arbitrator", true, uint(100_000_000))
+	CallContract(t, ct, "e_rotate", []byte("0|hive:receiver3"), nil, "hive:receiver", false, uint(100_000_000))
+	CallContract(t, ct, "e_rotate_respond", []byte("0|t|y"), nil, "hive:arbitrator", false, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:receiver2", false, uint(100_000_000))
+
+	// the approval of the remaining party applies it at once
+	CallContract(t, ct, "e_rotate_respond", []byte("0|t|y"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:receiver2", true, uint(100_000_000))
+}
+
+// a compromised seat cannot object to or approve its own replacement
+func TestRotationCompromisedSeat(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create",
+		[]byte("rotation|hive:receiver|hive:arbitrator"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_rotate", []byte("0|t|hive:receiver2"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_rotate_respond", []byte("0|t|n"), nil, "hive:receiver", false, uint(100_000_000))
+	CallContract(t, ct, "e_rotate_respond", []byte("0|t|y"), nil, "hive:receiver", false, uint(100_000_000))
+	CallContract(t, ct, "e_rotate_complete", []byte("0|t"), nil, "hive:sender", false, uint(100_000_000))
+	CallContract(t, ct, "e_rotate_respond", []byte("0|t|y"), nil, "hive:arbitrator", true, uint(100_000_000))
+
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:receiver2", true, uint(100_000_000))
+	assert.Equal(t, int64(1000), ct.GetBalance("hive:receiver2", ledgerDb.AssetHive))
+}
+
+// a rotation drops the forward instruction and the offer registered by the old address
+func TestRotationClearsSeat(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create",
+		[]byte("rotation|hive:receiver|hive:arbitrator"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_forward", []byte("0|5000|diverted|hive:attacker|hive:arbitrator2"), nil, "hive:receiver", true, uint(100_000_000))
+	CallContract(t, ct, "e_offer", []byte("0|2000|1000000"), nil, "hive:receiver", true, uint(100_000_000))
+	CallContract(t, ct, "e_rotate", []byte("0|t|hive:receiver2"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_rotate_respond", []byte("0|t|y"), nil, "hive:arbitrator", true, uint(100_000_000))
+
+	CallContract(t, ct, "e_accept_offer", []byte("0|2000"), nil, "hive:sender", false, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:receiver2", true, uint(100_000_000))
+	assert.Equal(t, int64(1000), ct.GetBalance("hive:receiver2", ledgerDb.AssetHive))
+}
+
+// a rotation cannot move a seat to the refund address, a backer or one of multiple receivers
+func TestRotationNeutrality(t *testing.T) {
+	ct := SetupContractTest()
+	ct.Deposit("hive:backer", 1000, ledgerDb.AssetHive)
+
+	CallContract(t, ct, "e_create",
+		[]byte("sponsored|hive:receiver|hive:arbitrator|from=hive:manager"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "0.300", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_rotate", []byte("0|hive:sender"), nil, "hive:receiver", false, uint(100_000_000))
+
+	CallContract(t, ct, "e_create",
+		[]byte("split|hive:receiver|hive:arbitrator|rcv=hive:receiver:5000,hive:receiver2:5000"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "0.300", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_rotate", []byte("1|hive:receiver2"), nil, "hive:arbitrator", false, uint(100_000_000))
+
+	CallContract(t, ct, "e_create",
+		[]byte("cofunded|hive:receiver|hive:arbitrator|cof=1"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "0.300", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_cofund", []byte("2"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "0.300", "token": "hive"}}}, "hive:backer", true, uint(100_000_000))
+	CallContract(t, ct, "e_rotate", []byte("2|hive:backer"), nil, "hive:receiver", false, uint(100_000_000))
+}
@@ -0,0 +1,39 @@
+package contract_test
+
+import (
+	"testing"
+	"vsc-node/modules/db/vsc/contracts"
+	ledgerDb "vsc-node/modules/db/vsc/ledger"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// the explicit sender votes while refunds go back to the funder
+func TestSponsoredRefundToFunder(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create",
+		[]byte("sponsored job|hive:receiver|hive:arbitrator|from=hive:manager"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|f"), nil, "hive:sender", false, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|f"), nil, "hive:manager", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|f"), nil, "hive:arbitrator", true, uint(100_000_000))
+
+	assert.Equal(t, int64(1000), ct.GetBalance("hive:sender", ledgerDb.AssetHive))
+	assert.Equal(t, int64(0), ct.GetBalance("hive:manager", ledgerDb.AssetHive))
+}
+
+// the arbitrator must be neutral towards sender, receiver and funder
+func TestSponsoredArbitratorNeutral(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create",
+		[]byte("sponsored job|hive:receiver|hive:manager|from=hive:manager"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", false, uint(100_000_000))
+	CallContract(t, ct, "e_create",
+		[]byte("sponsored job|hive:receiver|hive:sender|from=hive:manager"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", false, uint(100_000_000))
+	CallContract(t, ct, "e_create",
+		[]byte("sponsored job|hive:receiver|hive:arbitrator|from=hive:manager|rfd=hive:arbitrator"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", false, uint(100_000_000))
+}
@@ -0,0 +1,77 @@
+package contract_test
+
+import (
+	"testing"
+	"vsc-node/modules/db/vsc/contracts"
+	ledgerDb "vsc-node/modules/db/vsc/ledger"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// an escrow in HBD savings releases savings to the receiver
+func TestStakedSavingsRelease(t *testing.T) {
+	ct := SetupContractTest()
+	ct.Deposit("hive:sender", 1000, ledgerDb.AssetHbdSavings)
+
+	CallContract(t, ct, "e_create",
+		[]byte("retainer|hive:receiver|hive:arbitrator"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hbd_savings"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:receiver", true, uint(100_000_000))
+
+	assert.Equal(t, int64(1000), ct.GetBalance("hive:receiver", ledgerDb.AssetHbdSavings))
+}
+
+// staked assets cannot be withdrawn to Hive L1
+func TestStakedNoWithdraw(t *testing.T) {
+	ct := SetupContractTest()
+	ct.Deposit("hive:sender", 1000, ledgerDb.AssetHbdSavings)
+
+	CallContract(t, ct, "e_create",
+		[]byte("retainer|hive:receiver|hive:arbitrator|wd=1"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hbd_savings"}}}, "hive:sender", false, uint(100_000_000))
+	CallContract(t, ct, "e_create",
+		[]byte("retainer|hive:receiver|hive:arbitrator"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hbd_savings"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_payout_to", []byte("0|w"), nil, "hive:receiver", false, uint(100_000_000))
+}
+
+// staked consensus escrows reject withdrawals to any Hive account
+func TestStakedConsensusNoWithdraw(t *testing.T) {
+	ct := SetupContractTest()
+	ct.Deposit("hive:sender", 1000, ledgerDb.AssetHiveCons)
+
+	CallContract(t, ct, "e_create",
+		[]byte("retainer|hive:receiver|hive:arbitrator|wd=1"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive_consensus"}}}, "hive:sender", false, uint(100_000_000))
+	CallContract(t, ct, "e_create",
+		[]byte("retainer|hive:receiver|hive:arbitrator"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive_consensus"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_payout_to", []byte("0|w|hive:coldwallet"), nil, "hive:receiver", false, uint(100_000_000))
+
+	// transfers to another address stay possible
+	CallContract(t, ct, "e_payout_to", []byte("0|t|hive:coldwallet"), nil, "hive:receiver", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:receiver", true, uint(100_000_000))
+	assert.Equal(t, int64(1000), ct.GetBalance("hive:coldwallet", ledgerDb.AssetHiveCons))
+}
+
+// liquid funds cannot be drawn into a staked escrow
+func TestStakedNoLiquidDraw(t *testing.T) {
+	ct := SetupContractTest()
+	ct.Deposit("hive:sender", 1000, ledgerDb.AssetHbdSavings)
+	ct.Deposit("hive:receiver", 1000, ledgerDb.AssetHbd)
+	ct.Deposit("hive:funder", 1000, ledgerDb.AssetHbd)
+
+	CallContract(t, ct, "e_create",
+		[]byte("retainer|hive:receiver|hive:arbitrator|bond=100"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "0.500", "token": "hbd_savings"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_bond", []byte("0"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "0.100", "token": "hbd"}}}, "hive:receiver", false, uint(100_000_000))
+
+	CallContract(t, ct, "e_create",
+		[]byte("shared retainer|hive:receiver|hive:arbitrator|cof=1"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "0.500", "token": "hbd_savings"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_cofund", []byte("1"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hbd"}}}, "hive:funder", false, uint(100_000_000))
+}
@@ -0,0 +1,49 @@
+package contract_test
+
+import (
+	"testing"
+	"vsc-node/modules/db/vsc/contracts"
+	ledgerDb "vsc-node/modules/db/vsc/ledger"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// create subscription; first period escrow is created and can be released
+func TestSubscriptionFirstPeriodRelease(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create_sub",
+		[]byte("retainer|hive:receiver|hive:arbitrator|300|1000|3"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	// only 3 periods are held
+	assert.Equal(t, int64(100), ct.GetBalance("hive:sender", ledgerDb.AssetHive))
+	CallContract(t, ct, "e_sub_get", []byte("0"), nil, "hive:sender", true, uint(100_000_000))
+
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:receiver", true, uint(100_000_000))
+	assert.Equal(t, int64(300), ct.GetBalance("hive:receiver", ledgerDb.AssetHive))
+}
+
+// create subscription with intent below one period
+func TestSubscriptionCreateIntentTooLow(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create_sub",
+		[]byte("retainer|hive:receiver|hive:arbitrator|3000|1000|3"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", false, uint(100_000_000))
+}
+
+// receiver stops the subscription; remaining balance goes back to the sender
+func TestSubscriptionStop(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create_sub",
+		[]byte("retainer|hive:receiver|hive:arbitrator|300|1000|3"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_sub_stop", []byte("0"), nil, "hive:arbitrator", false, uint(100_000_000))
+	CallContract(t, ct, "e_sub_stop", []byte("0"), nil, "hive:receiver", true, uint(100_000_000))
+	assert.Equal(t, int64(700), ct.GetBalance("hive:sender", ledgerDb.AssetHive))
+
+	// no more periods after stop
+	CallContract(t, ct, "e_sub_tick", []byte("0"), nil, "hive:receiver", false, uint(100_000_000))
+}
@@ -0,0 +1,58 @@
+package contract_test
+
+import (
+	"testing"
+	"vsc-node/modules/db/vsc/contracts"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// funding fails cleanly if the token contract does not move the tokens
+func TestTokenFundingFails(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create",
+		[]byte("token job|hive:receiver|hive:arbitrator"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "token:missingtoken"}}}, "hive:sender", false, uint(100_000_000))
+}
+
+// malformed token assets are rejected
+func TestTokenInvalidAsset(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create",
+		[]byte("token job|hive:receiver|hive:arbitrator"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "token:"}}}, "hive:sender", false, uint(100_000_000))
+	CallContract(t, ct, "e_create",
+		[]byte("token job|hive:receiver|hive:arbitrator"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "usdt"}}}, "hive:sender", false, uint(100_000_000))
+}
+
+// tokens are pulled against the payer's allowance and pushed to the receiver on release
+func TestTokenFundingAndPayout(t *testing.T) {
+	ct := SetupContractTest()
+	token := "token:" + HelperID
+
+	CallContractID(t, ct, HelperID, "mint", []byte("hive:sender|1000"), nil, "hive:sender", true, uint(100_000_000))
+	CallContractID(t, ct, HelperID, "approve", []byte("contract:"+ContractID+"|1000"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_create",
+		[]byte("token job|hive:receiver|hive:arbitrator"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": token}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:receiver", true, uint(100_000_000))
+
+	result, _, _ := CallContractID(t, ct, HelperID, "balanceOf", []byte("hive:receiver"), nil, "hive:receiver", true, uint(100_000_000))
+	assert.Equal(t, "1000", result.Ret)
+}
+
+// funding fails without a sufficient allowance for the escrow contract
+func TestTokenWithoutAllowance(t *testing.T) {
+	ct := SetupContractTest()
+	token := "token:" + HelperID
+
+	CallContractID(t, ct, HelperID, "mint", []byte("hive:sender|1000"), nil, "hive:sender", true, uint(100_000_000))
+	CallContractID(t, ct, HelperID, "approve", []byte("contract:"+ContractID+"|500"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_create",
+		[]byte("token job|hive:receiver|hive:arbitrator"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": token}}}, "hive:sender", false, uint(100_000_000))
+}
@@ -0,0 +1,61 @@
+package contract_test
+
+import (
+	"testing"
+	"vsc-node/modules/db/vsc/contracts"
+	ledgerDb "vsc-node/modules/db/vsc/ledger"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// both sides stake equally and the arbitrator declares the opponent as winner (5% fee)
+func TestWagerDeclareWinner(t *testing.T) {
+	ct := SetupContractTest()
+	ct.Deposit("hive:receiver", 1000, ledgerDb.AssetHive)
+
+	CallContract(t, ct, "e_create_wager",
+		[]byte("wager name|hive:receiver|hive:arbitrator|0|500"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+
+	// no result before the opponent staked
+	CallContract(t, ct, "e_declare", []byte("0|t"), nil, "hive:arbitrator", false, uint(100_000_000))
+
+	CallContract(t, ct, "e_stake", []byte("0"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:receiver", true, uint(100_000_000))
+
+	// wagers are not voted
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:receiver", false, uint(100_000_000))
+	CallContract(t, ct, "e_declare", []byte("0|t"), nil, "hive:receiver", false, uint(100_000_000))
+
+	CallContract(t, ct, "e_declare", []byte("0|t"), nil, "hive:arbitrator", true, uint(100_000_000))
+	assert.Equal(t, int64(1900), ct.GetBalance("hive:receiver", ledgerDb.AssetHive))
+	assert.Equal(t, int64(100), ct.GetBalance("hive:arbitrator", ledgerDb.AssetHive))
+}
+
+// a void result refunds both stakes without fee
+func TestWagerVoid(t *testing.T) {
+	ct := SetupContractTest()
+	ct.Deposit("hive:receiver", 1000, ledgerDb.AssetHive)
+
+	CallContract(t, ct, "e_create_wager",
+		[]byte("wager name|hive:receiver|hive:arbitrator|500|500"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_stake", []byte("0"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "0.500", "token": "hive"}}}, "hive:receiver", true, uint(100_000_000))
+	CallContract(t, ct, "e_declare", []byte("0|void"), nil, "hive:arbitrator", true, uint(100_000_000))
+	assert.Equal(t, int64(1000), ct.GetBalance("hive:sender", ledgerDb.AssetHive))
+	assert.Equal(t, int64(1000), ct.GetBalance("hive:receiver", ledgerDb.AssetHive))
+}
+
+// settling from an oracle requires a configured oracle
+func TestWagerSettleWithoutOracle(t *testing.T) {
+	ct := SetupContractTest()
+	ct.Deposit("hive:receiver", 1000, ledgerDb.AssetHive)
+
+	CallContract(t, ct, "e_create_wager",
+		[]byte("wager name|hive:receiver|hive:arbitrator|0|0"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_stake", []byte("0"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hive"}}}, "hive:receiver", true, uint(100_000_000))
+	CallContract(t, ct, "e_settle", []byte("0"), nil, "hive:sender", false, uint(100_000_000))
+}
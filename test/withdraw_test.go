@@ -0,0 +1,36 @@
+package contract_test
+
+import (
+	"testing"
+	"vsc-node/modules/db/vsc/contracts"
+	ledgerDb "vsc-node/modules/db/vsc/ledger"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// a release to a hive: receiver is withdrawn instead of credited on VSC
+func TestWithdrawToHive(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create",
+		[]byte("withdraw job|hive:receiver|hive:arbitrator|wd=1"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hbd"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:receiver", true, uint(100_000_000))
+
+	assert.Equal(t, int64(0), ct.GetBalance("hive:receiver", ledgerDb.AssetHbd))
+	assert.Equal(t, int64(0), ct.GetBalance("contract:"+ContractID, ledgerDb.AssetHbd))
+}
+
+// non-Hive receivers fall back to a transfer
+func TestWithdrawFallbackToTransfer(t *testing.T) {
+	ct := SetupContractTest()
+
+	CallContract(t, ct, "e_create",
+		[]byte("withdraw job|did:key:z6Mkreceiver|hive:arbitrator|wd=1"),
+		[]contracts.Intent{{Type: "transfer.allow", Args: map[string]string{"limit": "1.000", "token": "hbd"}}}, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:sender", true, uint(100_000_000))
+	CallContract(t, ct, "e_decide", []byte("0|r"), nil, "hive:arbitrator", true, uint(100_000_000))
+
+	assert.Equal(t, int64(1000), ct.GetBalance("did:key:z6Mkreceiver", ledgerDb.AssetHbd))
+}